@@ -1,107 +1,56 @@
 package app
 
 import (
-	"context"
 	"fmt"
 
 	"github.com/AbdelilahOu/Bubly-cli-app/types"
-	"github.com/AbdelilahOu/Bubly-cli-app/utils"
-	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
 	"github.com/muesli/reflow/indent"
-	"github.com/muesli/termenv"
 )
 
+// The styles are built from the theme's palette, see applyPalette
 var (
-	term      = termenv.EnvColorProfile()
-	subtle    = makeFgStyle("241")
-	dot       = colorFg(" • ", "236")
-	help      = subtle("j/k, up/down: select") + dot + subtle("enter: choose") + dot + subtle("q, esc: quit") + dot + subtle("backspace: back")
-	highlight = lipgloss.AdaptiveColor{Light: "#874BFD", Dark: "#7D56F4"}
+	TitleStyle   func(...string) string
+	SuccessStyle func(...string) string
+	ErrorStyle   func(...string) string
+	WarningStyle func(...string) string
 )
 
 var (
-	TitleStyle = lipgloss.NewStyle().
-			Align(lipgloss.Left).
-			Foreground(lipgloss.Color("#FAFAFA")).
-			Background(highlight).
-			Margin(1, 1, 0, 0).
-			Padding(0, 2).Render
-	SuccessStyle = lipgloss.NewStyle().
-			Align(lipgloss.Left).
-			Foreground(lipgloss.Color("#FAFAFA")).
-			Background(lipgloss.AdaptiveColor{Light: "#16a34a", Dark: "#16a34a"}).
-			Margin(1, 1, 0, 0).
-			Padding(0, 2).Render
-	ErrorStyle = lipgloss.NewStyle().
-			Align(lipgloss.Left).
-			Foreground(lipgloss.Color("#FAFAFA")).
-			Background(lipgloss.AdaptiveColor{Light: "#b91c1c", Dark: "#b91c1c"}).
-			Margin(1, 1, 0, 0).
-			Padding(0, 2).Render
-	WarningStyle = lipgloss.NewStyle().
-			Align(lipgloss.Left).
-			Foreground(lipgloss.Color("#FAFAFA")).
-			Background(lipgloss.AdaptiveColor{Light: "#f97316", Dark: "#f97316"}).
-			Margin(1, 1, 0, 0).
-			Padding(0, 2).Render
+	audioQualityStyle  func(...string) string
+	audioFormatStyle   func(...string) string
+	audioFileSizeStyle func(...string) string
 )
 
 var (
-	audioQualityStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#7D56F4")).
-				Padding(0, 1).
-				Render
-
-	audioFormatStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#FAFAFA")).
-				Background(lipgloss.Color("#16a34a")).
-				Padding(0, 1).
-				Render
-
-	audioFileSizeStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#f97316")).
-				Padding(0, 1).
-				Render
+	videoQualityStyle    func(...string) string
+	videoFormatStyle     func(...string) string
+	videoResolutionStyle func(...string) string
+	videoFileSizeStyle   func(...string) string
 )
 
 var (
-	videoQualityStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#7D56F4")).
-				Padding(0, 1).
-				Render
-
-	videoFormatStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#FAFAFA")).
-				Background(lipgloss.Color("#16a34a")).
-				Padding(0, 1).
-				Render
-
-	videoResolutionStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#f97316")).
-				Padding(0, 1).
-				Render
-
-	videoFileSizeStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#2563eb")).
-				Padding(0, 1).
-				Render
+	subtitleLangStyle     func(...string) string
+	subtitleSelectedStyle func(...string) string
 )
 
 var (
-	subtitleLangStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#7D56F4")).
-				Padding(0, 1).
-				Render
+	chapterNumberStyle func(...string) string
+	chapterTitleStyle  func(...string) string
+	chapterTimeStyle   func(...string) string
+)
+
+var urlErrorStyle func(...string) string
 
-	subtitleSelectedStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#FAFAFA")).
-				Background(lipgloss.Color("#16a34a")).
-				Padding(0, 1).
-				Render
+var (
+	infoPanelStyle func(...string) string
+	infoTitleStyle func(...string) string
+	infoLabelStyle func(...string) string
 )
 
+var selectedStyle func(...string) string
+
 type ViewsOptions struct {
 	View        string
 	ChoiceLabel string
@@ -110,15 +59,6 @@ type ViewsOptions struct {
 type AppModel struct {
 	Choice               int
 	Quitting             bool
-	History              []string
-	Textarea             textarea.Model
-	Text                 string
-	IsTextAreaActive     bool
-	IsUrlWritten         bool
-	PrintingIsDone       bool
-	PrintingError        bool
-	CancelBackgroudJob   context.CancelFunc
-	IsBackgroundJob      bool
 	Warning              string
 	CheckingYtdlp        bool
 	InstallingYtdlp      bool
@@ -126,56 +66,68 @@ type AppModel struct {
 	InstallationTotal    int
 	InstallationMessage  string
 	YtdlpInstalled       bool
-	AudioFormatSel       *AudioFormatSelection
-	VideoFormatSel       *VideoFormatSelection
-	SubtitleSel          *SubtitleSelection
-	Page                 int
-	ItemsPerPage         int
+	ShowHelp             bool
+	Router               *Router
+	Layout               *Layout
 }
 
 func (m AppModel) Init() tea.Cmd {
 	return tea.Batch(
-		func() tea.Msg {
-			return types.CheckYtdlpMsg{Installed: utils.CheckYtdlp()}
-		},
+		checkYtdlp(),
 	)
 }
 
 func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	if msg, ok := msg.(tea.KeyMsg); ok {
-		k := msg.String()
-		if m.IsTextAreaActive {
-			if k == "esc" || k == "ctrl+c" {
-				m.Quitting = true
-				return m, tea.Quit
-			}
-		} else {
-			if k == "q" || k == "esc" || k == "ctrl+c" {
-				m.Quitting = true
-				return m, tea.Quit
-			}
+		// while typing, keys that are text go to the input instead
+		typing := m.Router.Typing() && !m.CheckingYtdlp
+		global := !typing || !typedKey(msg)
+		if global && key.Matches(msg, keys.Quit) {
+			m.Quitting = true
+			return m, tea.Quit
 		}
-		if k == "backspace" && len(m.History) > 0 {
-			if m.IsBackgroundJob {
-				m.CancelBackgroudJob()
-				m.IsBackgroundJob = false
-			}
-			if m.Textarea.Value() == "" {
-				m.IsUrlWritten = false
-				m.PrintingError = false
-				m.PrintingIsDone = false
-				m = removeFromHistory(m)
-			} else {
-				m.Text = m.Textarea.Value()[:len(m.Textarea.Value())-1]
+		if m.ShowHelp {
+			m.ShowHelp = false
+			return m, nil
+		}
+		if global && key.Matches(msg, keys.Help) {
+			m.ShowHelp = true
+			return m, nil
+		}
+		if !typing && key.Matches(msg, keys.Back) && !m.CheckingYtdlp {
+			if m.Router.Pop() {
+				m.Warning = ""
 			}
+			return m, nil
 		}
 	}
 
+	if msg, ok := msg.(tea.WindowSizeMsg); ok {
+		m.Layout.Resize(msg.Width, msg.Height)
+		helpView.Width = m.Layout.ContentWidth()
+		return m, m.Router.Update(msg)
+	}
+
 	if m.CheckingYtdlp || m.InstallingYtdlp {
 		return UpdateYtdlp(msg, m)
 	}
 
-	return UpdateYoutube(msg, m)
+	switch msg := msg.(type) {
+	case PushScreenMsg:
+		return m, m.Router.Push(msg.Screen)
+	case PopScreenMsg:
+		m.Router.Pop()
+		m.Warning = msg.Warning
+		if msg.Result != nil {
+			return m, m.Router.UpdateTop(msg.Result)
+		}
+		return m, nil
+	case WarningMsg:
+		m.Warning = string(msg)
+		return m, nil
+	}
+
+	return m, m.Router.Update(msg)
 }
 
 func (m AppModel) View() string {
@@ -183,15 +135,39 @@ func (m AppModel) View() string {
 		return "" + TitleStyle("See you later! 👋") + ""
 	}
 
+	if m.ShowHelp {
+		return indent.String(TitleStyle("Keys")+"\n\n"+fullHelp(m.screenKeys(), []key.Binding{keys.Back, keys.Help, keys.Quit})+"\n\n"+shortHelp([]key.Binding{withDesc(keys.Help, "close")}), 2)
+	}
+
 	if m.CheckingYtdlp || m.InstallingYtdlp {
 		return YtdlpView(m)
 	}
 
-	s := YoutubeView(m)
+	s := m.Router.View(m.Layout) + "\n\n"
 	if m.Warning != "" {
-		return indent.String(""+s+""+help+"\n"+WarningStyle(m.Warning), 2)
+		return indent.String(""+s+""+m.helpFooter()+"\n"+WarningStyle(m.Layout.Wrap(m.Warning)), 2)
 	}
-	return indent.String(""+s+""+help, 2)
+	return indent.String(""+s+""+m.helpFooter(), 2)
+}
+
+// screenKeys are the keys of whatever is shown, the installer prompt or the current screen
+func (m AppModel) screenKeys() []key.Binding {
+	if m.CheckingYtdlp || m.InstallingYtdlp {
+		if m.InstallingYtdlp {
+			return nil
+		}
+		return []key.Binding{keys.Up, keys.Down, keys.Select}
+	}
+	return m.Router.Keys()
+}
+
+// helpFooter is the short help for the current screen, followed by the keys that work everywhere
+func (m AppModel) helpFooter() string {
+	bindings := m.screenKeys()
+	if m.Router.Depth() > 1 && !m.Router.Typing() && !m.CheckingYtdlp {
+		bindings = append(bindings, keys.Back)
+	}
+	return shortHelp(append(bindings, keys.Help, keys.Quit))
 }
 
 func UpdateYtdlp(msg tea.Msg, m AppModel) (tea.Model, tea.Cmd) {
@@ -220,12 +196,12 @@ func UpdateYtdlp(msg tea.Msg, m AppModel) (tea.Model, tea.Cmd) {
 		m.InstallationMessage = msg.Message
 		return m, nil
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "enter":
+		switch {
+		case key.Matches(msg, keys.Select):
 			if m.Choice == 0 {
 				if m.CheckingYtdlp {
 					m.InstallingYtdlp = true
-					return m, utils.InstallYtdlp()
+					return m, installYtdlp()
 				}
 			} else {
 				if m.CheckingYtdlp {
@@ -234,11 +210,11 @@ func UpdateYtdlp(msg tea.Msg, m AppModel) (tea.Model, tea.Cmd) {
 				}
 				return m, nil
 			}
-		case "up", "k":
+		case key.Matches(msg, keys.Up):
 			if m.Choice > 0 {
 				m.Choice--
 			}
-		case "down", "j":
+		case key.Matches(msg, keys.Down):
 			if m.Choice < 1 {
 				m.Choice++
 			}
@@ -269,7 +245,7 @@ func YtdlpView(m AppModel) string {
 		}
 	}
 
-	return indent.String(s+"\n"+help, 2)
+	return indent.String(s+"\n"+m.helpFooter(), 2)
 }
 
 func destructureOptions(options []ViewsOptions, c int) []any {
@@ -282,54 +258,7 @@ func destructureOptions(options []ViewsOptions, c int) []any {
 
 func checkbox(label string, checked bool) string {
 	if checked {
-		return colorFg("[x] "+label, "212")
+		return selectedStyle("[x] " + label)
 	}
 	return fmt.Sprintf("[ ] %s", label)
 }
-
-func appendToHistory(m AppModel, s string) AppModel {
-	m.History = append(m.History, s)
-	return m
-}
-func removeFromHistory(m AppModel) AppModel {
-
-	if len(m.History) > 0 {
-		switch m.History[len(m.History)-1] {
-		case "yt-download-audio":
-
-			m.AudioFormatSel = nil
-			m.IsUrlWritten = false
-			m.Text = ""
-			m.Textarea.Reset()
-		case "yt-download-video":
-
-			m.VideoFormatSel = nil
-			m.IsUrlWritten = false
-			m.Text = ""
-			m.Textarea.Reset()
-		case "yt-download-subtitles":
-
-			m.SubtitleSel = nil
-			m.IsUrlWritten = false
-			m.Text = ""
-			m.Textarea.Reset()
-		}
-	}
-
-	m.History = m.History[:len(m.History)-1]
-	m.Choice = 0
-
-	m.PrintingError = false
-	m.PrintingIsDone = false
-	m.Warning = ""
-
-	return m
-}
-
-func colorFg(val, color string) string {
-	return termenv.String(val).Foreground(term.Color(color)).String()
-}
-
-func makeFgStyle(color string) func(string) string {
-	return termenv.Style{}.Foreground(term.Color(color)).Styled
-}
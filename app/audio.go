@@ -4,12 +4,13 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/AbdelilahOu/Bubly-cli-app/config"
+	"github.com/AbdelilahOu/Bubly-cli-app/utils"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
@@ -29,83 +30,66 @@ type AudioFormatSelection struct {
 	Done        bool
 	Error       bool
 	ErrMsg      string
+	// JobID is the job of the queue downloading the format
+	JobID int
 }
 
-func (m AppModel) fetchAudioFormats(url string) tea.Cmd {
+func fetchAudioFormats(url string) tea.Cmd {
 	return func() tea.Msg {
-
-		os.MkdirAll("assets", 0755)
-
-		var path, ffmpegPath string
-		if isWindows() {
-			path = "bin/yt-dlp.exe"
-			ffmpegPath = "bin/ffmpeg.exe"
-		} else {
-			path = "bin/yt-dlp"
-			ffmpegPath = "bin/ffmpeg"
-		}
-
-		logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		formats, err := backend.AudioFormats(url)
 		if err != nil {
-			return AudioFormatMsg{Error: fmt.Sprintf("Error creating log file: %v", err)}
+			return AudioFormatMsg{URL: url, Error: err.Error()}
 		}
-		defer logFile.Close()
+		return AudioFormatMsg{URL: url, Formats: formats}
+	}
+}
 
-		var outBuf, errBuf strings.Builder
+func FetchAudioFormats(url string) ([]AudioFormat, error) {
+	os.MkdirAll(outputPath(), 0755)
 
-		_, err = os.Stat(ffmpegPath)
-		useFfmpeg := err == nil
+	var path, ffmpegPath string
+	if isWindows() {
+		path = "bin/yt-dlp.exe"
+		ffmpegPath = "bin/ffmpeg.exe"
+	} else {
+		path = "bin/yt-dlp"
+		ffmpegPath = "bin/ffmpeg"
+	}
 
-		var args []string
-		args = append(args, "-F", url)
+	logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating log file: %v", err)
+	}
+	defer logFile.Close()
 
-		if useFfmpeg {
-			args = append(args, "--ffmpeg-location", ffmpegPath)
-		} else {
-			// Add a warning to the log file if ffmpeg is not found
-			fmt.Fprintf(logFile, "Warning: ffmpeg not found. Some features may not work correctly.\n")
-		}
+	var outBuf, errBuf strings.Builder
 
-		cmd := exec.Command(path, args...)
-		cmd.Stdout = io.MultiWriter(&outBuf, logFile)
-		cmd.Stderr = io.MultiWriter(&errBuf, logFile)
-
-		err = cmd.Run()
-
-		debugFile, _ := os.OpenFile("debug.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		if debugFile != nil {
-			defer debugFile.Close()
-			fmt.Fprintf(debugFile, "Fetching formats for URL: %s\n", url)
-			fmt.Fprintf(debugFile, "Command executed, err: %v\n", err)
-			if err == nil {
-				output := outBuf.String()
-				fmt.Fprintf(debugFile, "Output length: %d\n", len(output))
-
-				if len(output) > 1000 {
-					fmt.Fprintf(debugFile, "Output (first 1000 chars): %s\n", output[:1000])
-				} else {
-					fmt.Fprintf(debugFile, "Output: %s\n", output)
-				}
-			} else {
-				fmt.Fprintf(debugFile, "Error output: %s\n", errBuf.String())
-			}
-		}
+	_, err = os.Stat(ffmpegPath)
+	useFfmpeg := err == nil
 
-		if err != nil {
-			return AudioFormatMsg{Error: fmt.Sprintf("Error fetching formats: %v. Check output.log for details.", err)}
-		}
+	var args []string
+	args = append(args, "-F", url)
 
-		formats := ParseAudioFormats(outBuf.String())
+	if useFfmpeg {
+		args = append(args, "--ffmpeg-location", ffmpegPath)
+	} else {
+		// Add a warning to the log file if ffmpeg is not found
+		fmt.Fprintf(logFile, "Warning: ffmpeg not found. Some features may not work correctly.\n")
+	}
 
-		if debugFile != nil {
-			fmt.Fprintf(debugFile, "Parsed %d formats\n", len(formats))
-			for i, f := range formats {
-				fmt.Fprintf(debugFile, "Format %d: ID=%s, Quality=%s\n", i, f.ID, f.Quality)
-			}
-		}
+	cmd := ytdlpCommand(path, args...)
+	cmd.Stdout = io.MultiWriter(&outBuf, logFile)
+	cmd.Stderr = io.MultiWriter(&errBuf, logFile)
 
-		return AudioFormatMsg{URL: url, Formats: formats}
+	err = cmd.Run()
+
+	if err != nil {
+		return nil, ytdlpError("fetching formats", err, errBuf.String())
 	}
+
+	formats := ParseAudioFormats(outBuf.String())
+
+	return formats, nil
 }
 
 func ParseAudioFormats(output string) []AudioFormat {
@@ -117,7 +101,7 @@ func ParseAudioFormats(output string) []AudioFormat {
 			strings.Contains(line, "ID  EXT") ||
 			strings.Contains(line, "----") ||
 			strings.TrimSpace(line) == "" ||
-			strings.Contains(line, "[youtube]") {
+			strings.HasPrefix(line, "[") {
 			continue
 		}
 
@@ -141,7 +125,7 @@ func ParseAudioFormats(output string) []AudioFormat {
 						}
 					}
 
-					if strings.Contains(field, "MiB") || strings.Contains(field, "KiB") {
+					if _, ok := utils.ParseSize(field); ok {
 						filesize = field
 					}
 				}
@@ -229,78 +213,111 @@ func extractBitrate(quality string) int {
 	return 0
 }
 
-func (m AppModel) downloadAudio(url string, formatID string) tea.Cmd {
+// downloadAudio waits for a job of the shared queue
+func downloadAudio(id int) tea.Cmd {
 	return func() tea.Msg {
-
-		os.MkdirAll("assets", 0755)
-
-		var path, ffmpegPath string
-		if isWindows() {
-			path = "bin/yt-dlp.exe"
-			ffmpegPath = "bin/ffmpeg.exe"
-		} else {
-			path = "bin/yt-dlp"
-			ffmpegPath = "bin/ffmpeg"
+		job, _ := SharedQueue().WaitFor(id)
+		if job.Status != JobDone {
+			return AudioDownloadMsg{JobID: id, Error: job.Error}
 		}
+		return AudioDownloadMsg{JobID: id, Done: true, Files: job.Files}
+	}
+}
 
-		logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		if err != nil {
-			return AudioDownloadMsg{Error: fmt.Sprintf("Error creating log file: %v", err)}
-		}
-		defer logFile.Close()
+func doDownloadAudio(job *Job) error {
+	os.MkdirAll(outputPath(), 0755)
 
-		var outBuf, errBuf strings.Builder
+	var path, ffmpegPath string
+	if isWindows() {
+		path = "bin/yt-dlp.exe"
+		ffmpegPath = "bin/ffmpeg.exe"
+	} else {
+		path = "bin/yt-dlp"
+		ffmpegPath = "bin/ffmpeg"
+	}
 
-		_, err = os.Stat(ffmpegPath)
-		useFfmpeg := err == nil
+	logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("Error creating log file: %v", err)
+	}
+	defer logFile.Close()
 
-		var args []string
-		args = append(args, "-f", formatID, "-x", "--audio-quality", "0")
+	var outBuf, errBuf strings.Builder
+	// deferred so that downloads failing halfway through still have their title
+	defer func() { job.reportTitle(printedTitle(outBuf.String())) }()
 
-		if useFfmpeg {
-			args = append(args, "--ffmpeg-location", ffmpegPath)
-		} else {
-			// Add a warning to the log file if ffmpeg is not found
-			fmt.Fprintf(logFile, "Warning: ffmpeg not found. Some features may not work correctly.\n")
-		}
+	_, err = os.Stat(ffmpegPath)
+	useFfmpeg := err == nil
 
-		args = append(args, "--sleep-requests", "1", "--sleep-interval", "5", "--max-sleep-interval", "10")
-		args = append(args, "-o", "assets/audio.%(ext)s", url)
+	formatID := job.Format
+	if formatID == "" {
+		formatID = config.Current().AudioFormatFor(DetectSite(job.URL).Name)
+	}
 
-		cmd := exec.Command(path, args...)
-		cmd.Stdout = io.MultiWriter(&outBuf, logFile)
-		cmd.Stderr = io.MultiWriter(&errBuf, logFile)
-		err = cmd.Run()
+	if !useFfmpeg {
+		// Add a warning to the log file if ffmpeg is not found
+		fmt.Fprintf(logFile, "Warning: ffmpeg not found. Some features may not work correctly.\n")
+	}
 
-		if err != nil {
-			errorOutput := errBuf.String()
+	output := job.OutputName(outputPath(defaultOutputName)) + ".%(ext)s"
+	args := audioArgs(job, formatID, ffmpegPath, useFfmpeg, output)
 
-			if strings.Contains(errorOutput, "403") || strings.Contains(errorOutput, "Forbidden") {
+	cmd := job.command(path, args...)
+	cmd.Stdout = io.MultiWriter(&outBuf, logFile, job.progressWriter())
+	cmd.Stderr = io.MultiWriter(&errBuf, logFile)
+	err = cmd.Run()
 
-				args = []string{"-f", "bestaudio", "-x", "--audio-quality", "0"}
+	if err != nil {
+		errorOutput := errBuf.String()
 
-				if useFfmpeg {
-					args = append(args, "--ffmpeg-location", ffmpegPath)
-				}
+		if strings.Contains(errorOutput, "403") || strings.Contains(errorOutput, "Forbidden") {
 
-				args = append(args, "--sleep-requests", "1", "--sleep-interval", "5", "--max-sleep-interval", "10")
-				args = append(args, "-o", "assets/audio.%(ext)s", url)
+			// the retry's output replaces the failed attempt's, errors and file name included
+			outBuf.Reset()
+			errBuf.Reset()
+			args = audioArgs(job, "bestaudio", ffmpegPath, useFfmpeg, output)
 
-				cmd = exec.Command(path, args...)
-				cmd.Stdout = io.MultiWriter(&outBuf, logFile)
-				cmd.Stderr = io.MultiWriter(&errBuf, logFile)
-				err = cmd.Run()
+			cmd = job.command(path, args...)
+			cmd.Stdout = io.MultiWriter(&outBuf, logFile, job.progressWriter())
+			cmd.Stderr = io.MultiWriter(&errBuf, logFile)
+			err = cmd.Run()
 
-				if err != nil {
-					return AudioDownloadMsg{Error: fmt.Sprintf("Error downloading audio: %v. Check output.log for details.", err)}
-				}
-			} else {
-				return AudioDownloadMsg{Error: fmt.Sprintf("Error downloading audio: %v. Check output.log for details.", err)}
+			if err != nil {
+				return ytdlpError("downloading audio", err, errBuf.String())
 			}
+		} else {
+			return ytdlpError("downloading audio", err, errBuf.String())
 		}
+	}
 
-		return AudioDownloadMsg{Done: true}
+	if file := lastLine(outBuf.String()); file != "" {
+		job.reportFiles([]string{file})
 	}
+	return nil
+}
+
+// audioArgs are the yt-dlp arguments of an audio job, for the format picked as well as the
+// fallback format retried after a 403
+func audioArgs(job *Job, formatID string, ffmpegPath string, useFfmpeg bool, output string) []string {
+	args := []string{"-f", formatID, "-x", "--audio-quality", "0"}
+
+	if useFfmpeg {
+		args = append(args, "--ffmpeg-location", ffmpegPath)
+	}
+
+	if job.Clip != nil {
+		args = append(args, "--download-sections", job.Clip.Section())
+		if useFfmpeg {
+			args = append(args, "--force-keyframes-at-cuts")
+		}
+	}
+
+	args = append(args, sponsorBlockArgs(job)...)
+	args = append(args, rateLimitArgs(job.rate)...)
+	args = append(args, "--sleep-requests", "1", "--sleep-interval", "5", "--max-sleep-interval", "10", "--newline")
+	args = append(args, titleArgs...)
+	args = append(args, "--print", "after_move:filepath", "--no-quiet")
+	return append(args, "-o", output, job.URL)
 }
 
 type AudioFormatMsg struct {
@@ -310,8 +327,10 @@ type AudioFormatMsg struct {
 }
 
 type AudioDownloadMsg struct {
+	JobID int
 	Done  bool
 	Error string
+	Files []string
 }
 
 func isWindows() bool {
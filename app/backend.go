@@ -0,0 +1,105 @@
+package app
+
+import (
+	"github.com/AbdelilahOu/Bubly-cli-app/types"
+	"github.com/AbdelilahOu/Bubly-cli-app/utils"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Backend does the actual fetching and downloading behind the screens, the queue and the API.
+// The yt-dlp backend is the real one, the demo backend replays canned videos.
+type Backend interface {
+	YtdlpInstalled() bool
+	InstallYtdlp() error
+	// Extractors are the names of the sites yt-dlp knows, see FetchExtractors
+	Extractors() ([]string, error)
+	VideoInfo(url string) (*VideoInfo, error)
+	AudioFormats(url string) ([]AudioFormat, error)
+	VideoFormats(url string) ([]VideoFormat, error)
+	SubtitleLanguages(url string) ([]SubtitleLanguage, error)
+	// SponsorSegments are the SponsorBlock segments of a YouTube video
+	SponsorSegments(videoID string) ([]SponsorSegment, error)
+	// Search returns up to limit YouTube videos for the keywords of query
+	Search(query string, limit int) ([]SearchResult, error)
+	// Run downloads a queued job, reporting progress and stopping when the job is cancelled
+	Run(job *Job) error
+	// SaveThumbnail returns the saved file, which is set even when only the conversion failed
+	SaveThumbnail(thumb Thumbnail, convertTo string) (string, error)
+}
+
+var backend Backend = ytdlpBackend{}
+
+func UseBackend(b Backend) {
+	backend = b
+}
+
+type ytdlpBackend struct{}
+
+func (ytdlpBackend) YtdlpInstalled() bool {
+	return utils.CheckYtdlp()
+}
+
+func (ytdlpBackend) InstallYtdlp() error {
+	return utils.DownloadYtdlp()
+}
+
+func (ytdlpBackend) Extractors() ([]string, error) {
+	return FetchExtractors()
+}
+
+func (ytdlpBackend) VideoInfo(url string) (*VideoInfo, error) {
+	return FetchVideoInfo(url)
+}
+
+func (ytdlpBackend) AudioFormats(url string) ([]AudioFormat, error) {
+	return FetchAudioFormats(url)
+}
+
+func (ytdlpBackend) VideoFormats(url string) ([]VideoFormat, error) {
+	return FetchVideoFormats(url)
+}
+
+func (ytdlpBackend) SubtitleLanguages(url string) ([]SubtitleLanguage, error) {
+	return FetchSubtitleLanguages(url)
+}
+
+func (ytdlpBackend) SponsorSegments(videoID string) ([]SponsorSegment, error) {
+	return FetchSponsorSegments(videoID)
+}
+
+func (ytdlpBackend) Search(query string, limit int) ([]SearchResult, error) {
+	return FetchSearch(query, limit)
+}
+
+func (ytdlpBackend) Run(job *Job) error {
+	if job.Live != "" {
+		return doRecordLive(job)
+	}
+	if job.Chapters != nil {
+		return doDownloadChapters(job)
+	}
+	switch job.Kind {
+	case JobAudio:
+		return doDownloadAudio(job)
+	case JobSubtitles:
+		return doDownloadSubtitles(job)
+	default:
+		return doDownloadVideo(job)
+	}
+}
+
+func (ytdlpBackend) SaveThumbnail(thumb Thumbnail, convertTo string) (string, error) {
+	return SaveThumbnail(thumb, convertTo)
+}
+
+func checkYtdlp() tea.Cmd {
+	return func() tea.Msg {
+		return types.CheckYtdlpMsg{Installed: backend.YtdlpInstalled()}
+	}
+}
+
+func installYtdlp() tea.Cmd {
+	return func() tea.Msg {
+		return types.YtdlpInstalledMsg{Err: backend.InstallYtdlp()}
+	}
+}
@@ -0,0 +1,280 @@
+package app
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/config"
+)
+
+type BatchEntry struct {
+	Line int
+	Raw  string
+	Job  *Job
+	Err  error
+}
+
+// ParseBatch reads one url per line. Blank lines and # comments are skipped and
+// a line can override the defaults with kind=, format=, lang=, sponsorblock= and rate_limit=
+// after the url:
+//
+//	https://youtu.be/jNQXAC9IVRw kind=audio format=140 sponsorblock=remove rate_limit=500K
+func ParseBatch(r io.Reader, defaults Job) ([]BatchEntry, error) {
+	var entries []BatchEntry
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if idx := strings.Index(line, " #"); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entry := BatchEntry{Line: lineNo, Raw: line}
+		entry.Job, entry.Err = parseBatchLine(line, defaults)
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+func parseBatchLine(line string, defaults Job) (*Job, error) {
+	fields := strings.Fields(line)
+
+	parsed, err := ParseVideoURL(fields[0])
+	if err != nil {
+		return nil, err
+	}
+
+	job := defaults
+	job.URL = parsed.String()
+
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", field)
+		}
+		switch key {
+		case "kind":
+			kind, err := ParseJobKind(value)
+			if err != nil {
+				return nil, err
+			}
+			job.Kind = kind
+		case "format":
+			job.Format = value
+		case "lang":
+			job.Lang = value
+		case "sponsorblock":
+			if value != "remove" && value != "mark" && value != "off" {
+				return nil, fmt.Errorf("sponsorblock must be remove, mark or off, not %q", value)
+			}
+			job.SponsorBlock = value
+		case "rate_limit":
+			if _, err := config.ParseRate(value); err != nil {
+				return nil, err
+			}
+			job.RateLimit = value
+		default:
+			return nil, fmt.Errorf("unknown option %q, use kind=, format=, lang=, sponsorblock= or rate_limit=", key)
+		}
+	}
+
+	return &job, nil
+}
+
+func RunBatchCommand(args []string) int {
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: bubly batch [flags] [file]\n\nReads urls from file, or from stdin when file is - or missing.\n\nFlags:")
+		fs.PrintDefaults()
+	}
+	kind := fs.String("kind", string(JobVideo), "default download kind: video, audio or subtitles")
+	format := fs.String("format", "", "default yt-dlp format id")
+	lang := fs.String("lang", "en", "default subtitle language")
+	output := fs.String("output", outputPath(defaultOutputName), "yt-dlp output template, without the extension")
+	concurrency := fs.Int("concurrency", config.Current().Concurrency, "number of downloads to run at once")
+	rateLimit := fs.String("rate-limit", "", "default speed limit of each download, like 500K or 2M")
+	report := fs.String("report", config.Current().Report, "file to write a report of the jobs to, .csv or .json")
+	jsonOut := fs.Bool("json", false, "print the report as JSON on stdout, progress goes to stderr")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *concurrency < 1 || *concurrency > config.MaxConcurrency {
+		fmt.Fprintf(os.Stderr, "bubly batch: -concurrency must be between 1 and %d\n", config.MaxConcurrency)
+		return 2
+	}
+	defaultKind, err := ParseJobKind(*kind)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bubly batch:", err)
+		return 2
+	}
+	if _, err := config.ParseRate(*rateLimit); err != nil {
+		fmt.Fprintln(os.Stderr, "bubly batch: -rate-limit:", err)
+		return 2
+	}
+
+	var input io.Reader = os.Stdin
+	if name := fs.Arg(0); name != "" && name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "bubly batch:", err)
+			return 1
+		}
+		defer file.Close()
+		input = file
+	}
+
+	if !backend.YtdlpInstalled() {
+		fmt.Fprintln(os.Stderr, "bubly batch: yt-dlp was not found in bin/, run bubly once to install it")
+		return 1
+	}
+	LoadExtractors()
+
+	entries, err := ParseBatch(input, Job{Kind: defaultKind, Format: *format, Lang: *lang, Output: *output, RateLimit: *rateLimit})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bubly batch: reading urls:", err)
+		return 1
+	}
+
+	var out io.Writer = os.Stdout
+	if *jsonOut {
+		out = os.Stderr
+	}
+	results, code := runBatch(entries, *concurrency, out)
+
+	if *jsonOut {
+		WriteReportJSON(os.Stdout, results)
+	}
+	if path, err := SaveReport(*report, results); err != nil {
+		fmt.Fprintln(os.Stderr, "bubly batch:", err)
+		code = 1
+	} else if path != "" {
+		fmt.Fprintln(out, "Report saved to", path)
+	}
+	return code
+}
+
+// runBatch runs the valid lines and returns the report of the jobs, lines that were skipped
+// included, and the exit code
+func runBatch(entries []BatchEntry, concurrency int, out io.Writer) ([]ReportEntry, int) {
+	started := time.Now()
+
+	var valid int
+	for _, entry := range entries {
+		if entry.Err != nil {
+			fmt.Fprintf(out, "! line %d skipped: %v\n", entry.Line, entry.Err)
+		} else {
+			valid++
+		}
+	}
+
+	// progress updates are running updates too, each job is announced once
+	var mu sync.Mutex
+	announced := map[int]bool{}
+	scheduled := map[int]time.Time{}
+
+	queue := NewQueue(concurrency)
+	queue.OnUpdate = func(job Job) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch job.Status {
+		case JobQueued:
+			if job.ScheduledFor.IsZero() || job.ScheduledFor.Equal(scheduled[job.ID]) {
+				return
+			}
+			scheduled[job.ID] = job.ScheduledFor
+			fmt.Fprintf(out, "[%d/%d] ⏰ scheduled for %s\n", job.ID, valid, scheduleLabel(job.ScheduledFor))
+		case JobRunning:
+			if announced[job.ID] {
+				return
+			}
+			announced[job.ID] = true
+			fmt.Fprintf(out, "[%d/%d] ▶ %s %s\n", job.ID, valid, job.Kind, job.URL)
+			if job.Warning != "" {
+				fmt.Fprintf(out, "[%d/%d] ! %s\n", job.ID, valid, job.Warning)
+			}
+		case JobDone:
+			fmt.Fprintf(out, "[%d/%d] ✓ done in %s\n", job.ID, valid, job.Elapsed().Round(time.Second))
+		case JobFailed:
+			fmt.Fprintf(out, "[%d/%d] ✗ %s\n", job.ID, valid, job.Error)
+		}
+	}
+
+	stopNotifications := WatchQueue(queue, out)
+
+	lines := map[int]int{}
+	for _, entry := range entries {
+		if entry.Err == nil {
+			job := queue.Add(entry.Job)
+			lines[job.ID] = entry.Line
+		}
+	}
+	queue.Wait()
+	stopNotifications()
+
+	code := printBatchSummary(out, entries, queue.Jobs(), lines, time.Since(started))
+	return batchReport(entries, queue.Jobs()), code
+}
+
+// batchReport is the report of the jobs, with an entry for each line that was skipped
+func batchReport(entries []BatchEntry, jobs []Job) []ReportEntry {
+	report := NewReport(jobs)
+	for _, entry := range entries {
+		if entry.Err != nil {
+			report = append(report, ReportEntry{
+				URL:        entry.Raw,
+				Files:      []string{},
+				Status:     "skipped",
+				Error:      fmt.Sprintf("line %d: %v", entry.Line, entry.Err),
+				ErrorClass: "invalid_line",
+			})
+		}
+	}
+	return report
+}
+
+func printBatchSummary(out io.Writer, entries []BatchEntry, jobs []Job, lines map[int]int, elapsed time.Duration) int {
+	var done, failed, skipped int
+	for _, entry := range entries {
+		if entry.Err != nil {
+			skipped++
+		}
+	}
+	for _, job := range jobs {
+		if job.Status == JobDone {
+			done++
+		} else {
+			failed++
+		}
+	}
+
+	fmt.Fprintf(out, "\nBatch summary: %d done, %d failed, %d skipped in %s\n", done, failed, skipped, elapsed.Round(time.Second))
+	for _, job := range jobs {
+		if job.Status != JobDone {
+			fmt.Fprintf(out, "  ✗ line %d %s: %s\n", lines[job.ID], job.URL, job.Error)
+		}
+	}
+	for _, entry := range entries {
+		if entry.Err != nil {
+			fmt.Fprintf(out, "  ! line %d %s: %v\n", entry.Line, entry.Raw, entry.Err)
+		}
+	}
+
+	if failed > 0 || skipped > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBatch(t *testing.T) {
+	input := strings.Join([]string{
+		"# downloads for the weekend",
+		"",
+		"https://youtu.be/jNQXAC9IVRw",
+		"jNQXAC9IVRw kind=audio format=140 # the audio only",
+		"https://youtu.be/jNQXAC9IVRw kind=subtitles lang=fr",
+		"https://youtu.be/jNQXAC9IVRw sponsorblock=remove rate_limit=500k",
+		"https://youtu.be/jNQXAC9IVRw kind=podcast",
+		"https://youtu.be/jNQXAC9IVRw format",
+		"https://youtu.be/jNQXAC9IVRw quality=high",
+		"https://youtu.be/jNQXAC9IVRw sponsorblock=skip",
+		"https://youtu.be/jNQXAC9IVRw rate_limit=fast",
+		"not a url",
+	}, "\n")
+	defaults := Job{Kind: JobVideo, Lang: "en"}
+
+	tests := []struct {
+		line int
+		want Job
+		err  bool
+	}{
+		{line: 3, want: Job{Kind: JobVideo, URL: "https://www.youtube.com/watch?v=jNQXAC9IVRw", Lang: "en"}},
+		{line: 4, want: Job{Kind: JobAudio, URL: "https://www.youtube.com/watch?v=jNQXAC9IVRw", Format: "140", Lang: "en"}},
+		{line: 5, want: Job{Kind: JobSubtitles, URL: "https://www.youtube.com/watch?v=jNQXAC9IVRw", Lang: "fr"}},
+		{line: 6, want: Job{Kind: JobVideo, URL: "https://www.youtube.com/watch?v=jNQXAC9IVRw", Lang: "en", SponsorBlock: "remove", RateLimit: "500k"}},
+		{line: 7, err: true},
+		{line: 8, err: true},
+		{line: 9, err: true},
+		{line: 10, err: true},
+		{line: 11, err: true},
+		{line: 12, err: true},
+	}
+
+	entries, err := ParseBatch(strings.NewReader(input), defaults)
+	if err != nil {
+		t.Fatalf("ParseBatch failed: %v", err)
+	}
+	if len(entries) != len(tests) {
+		t.Fatalf("ParseBatch read %d entries, want %d", len(entries), len(tests))
+	}
+	for i, tt := range tests {
+		entry := entries[i]
+		if entry.Line != tt.line {
+			t.Errorf("entry %d is line %d, want %d", i, entry.Line, tt.line)
+		}
+		if tt.err {
+			if entry.Err == nil {
+				t.Errorf("line %d %q: want an error", entry.Line, entry.Raw)
+			}
+			continue
+		}
+		if entry.Err != nil {
+			t.Errorf("line %d %q failed: %v", entry.Line, entry.Raw, entry.Err)
+			continue
+		}
+		got := *entry.Job
+		if got.Kind != tt.want.Kind || got.URL != tt.want.URL || got.Format != tt.want.Format || got.Lang != tt.want.Lang ||
+			got.SponsorBlock != tt.want.SponsorBlock || got.RateLimit != tt.want.RateLimit {
+			t.Errorf("line %d %q = %+v, want %+v", entry.Line, entry.Raw, got, tt.want)
+		}
+	}
+}
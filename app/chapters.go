@@ -0,0 +1,380 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/utils"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var ChapterActions = []ViewsOptions{
+	{
+		View:        "chapters-audio",
+		ChoiceLabel: "Download selected chapters as audio 🎵",
+	},
+	{
+		View:        "chapters-video",
+		ChoiceLabel: "Download selected chapters as video 📥",
+	},
+	{
+		View:        "split-audio",
+		ChoiceLabel: "Split audio into one file per chapter ✂️",
+	},
+	{
+		View:        "split-video",
+		ChoiceLabel: "Split video into one file per chapter ✂️",
+	},
+}
+
+type ChapterSelection struct {
+	URL      string
+	Info     *VideoInfo
+	Choice   int
+	Marked   []bool
+	WriteCue bool
+}
+
+func (c *ChapterSelection) MarkedIndices() []int {
+	var indices []int
+	for i, marked := range c.Marked {
+		if marked {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+func chapterDir(info *VideoInfo) string {
+	return outputPath("chapters", utils.SanitizeFilename(info.Title))
+}
+
+func templateEscape(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+// ChapterJob makes a job download chapters of a video: the ones of Indices, or all of them
+// into one file each with Split, which can also write a cue sheet for the whole file
+type ChapterJob struct {
+	Indices []int `json:"indices,omitempty"`
+	Split   bool  `json:"split,omitempty"`
+	Cue     bool  `json:"cue,omitempty"`
+	// info is the video the chapters were picked from, fetched again when missing
+	info *VideoInfo
+}
+
+// NewChapterJob is the job downloading the chapters of info as audio or video
+func NewChapterJob(url string, info *VideoInfo, audio bool, chapters ChapterJob) *Job {
+	chapters.info = info
+	job := &Job{Kind: JobVideo, URL: url, Title: info.Title, Format: "bestvideo*+bestaudio/best", Chapters: &chapters}
+	if audio {
+		job.Kind, job.Format = JobAudio, "bestaudio"
+	}
+	return job
+}
+
+// downloadChapters waits for a chapter job of the shared queue
+func downloadChapters(id int, info *VideoInfo) tea.Cmd {
+	return func() tea.Msg {
+		job, _ := SharedQueue().WaitFor(id)
+		msg := ChapterDownloadMsg{JobID: id, Dir: chapterDir(info), Files: job.Files}
+		if job.Status != JobDone {
+			msg.Error = job.Error
+		}
+		return msg
+	}
+}
+
+func doDownloadChapters(job *Job) error {
+	info := job.Chapters.info
+	if info == nil {
+		var err error
+		if info, err = backend.VideoInfo(job.URL); err != nil {
+			return err
+		}
+	}
+	os.MkdirAll(chapterDir(info), 0755)
+	if job.Chapters.Split {
+		return splitChapters(job, info)
+	}
+	return downloadChapterSections(job, info)
+}
+
+// chapterArgs are the arguments the chapter downloads share
+func chapterArgs(job *Job, logFile io.Writer) []string {
+	var args []string
+	if job.Kind == JobAudio {
+		args = append(args, "-f", "bestaudio", "-x", "--audio-quality", "0")
+	} else {
+		args = append(args, "-f", "bestvideo*+bestaudio/best")
+	}
+
+	ffmpegPath := "bin/ffmpeg"
+	if isWindows() {
+		ffmpegPath = "bin/ffmpeg.exe"
+	}
+	if _, err := os.Stat(ffmpegPath); err == nil {
+		args = append(args, "--ffmpeg-location", ffmpegPath)
+	} else {
+		// cutting and splitting rely on ffmpeg, yt-dlp will still try the one on PATH
+		fmt.Fprintf(logFile, "Warning: ffmpeg not found in bin. Chapter downloads require ffmpeg.\n")
+	}
+	return append(args, rateLimitArgs(job.rate)...)
+}
+
+// downloadChapterSections downloads the chosen chapters one at a time, the progress of the
+// job going up by a share for each
+func downloadChapterSections(job *Job, info *VideoInfo) error {
+	path := "bin/yt-dlp"
+	if isWindows() {
+		path = "bin/yt-dlp.exe"
+	}
+
+	logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("Error creating log file: %v", err)
+	}
+	defer logFile.Close()
+
+	dir := chapterDir(info)
+	indices := job.Chapters.Indices
+	var files []string
+	for n, idx := range indices {
+		if idx < 0 || idx >= len(info.Chapters) {
+			return fmt.Errorf("the video has no chapter %d", idx+1)
+		}
+		chapter := info.Chapters[idx]
+		name := fmt.Sprintf("%02d - %s", idx+1, utils.SanitizeFilename(chapter.Title))
+
+		args := chapterArgs(job, logFile)
+		args = append(args, "--download-sections", fmt.Sprintf("*%.3f-%.3f", chapter.StartTime, chapter.EndTime))
+		args = append(args, sectionMetadataArgs(chapter.Title, idx+1, len(info.Chapters))...)
+		args = append(args, "--sleep-requests", "1", "--newline")
+		args = append(args, "--print", "after_move:filepath", "--no-quiet")
+		args = append(args, "-o", filepath.Join(templateEscape(dir), templateEscape(name)+".%(ext)s"), job.URL)
+
+		done := float64(n)
+		progress := &progressWriter{report: func(percent float64) {
+			if job.onProgress != nil {
+				job.onProgress((done*100 + percent) / float64(len(indices)))
+			}
+		}}
+
+		var outBuf, errBuf strings.Builder
+		cmd := job.command(path, args...)
+		cmd.Stdout = io.MultiWriter(&outBuf, logFile, progress)
+		cmd.Stderr = io.MultiWriter(&errBuf, logFile)
+		err = cmd.Run()
+		if err != nil {
+			job.reportFiles(files)
+			return ytdlpError(fmt.Sprintf("downloading chapter %q", chapter.Title), err, errBuf.String())
+		}
+
+		if file := lastLine(outBuf.String()); file != "" {
+			files = append(files, file)
+		}
+	}
+
+	job.reportFiles(files)
+	return nil
+}
+
+// sectionMetadataArgs tag a chapter download with the chapter's title and its track number
+// n of total. The title is parsed from a literal, : separates yt-dlp's FROM and TO and %
+// starts a field, and the meta_ fields are what --embed-metadata writes as they are.
+func sectionMetadataArgs(title string, n, total int) []string {
+	from := strings.NewReplacer("%", "%%", ":", `\:`).Replace(fmt.Sprintf("%s (%d/%d)", title, n, total))
+	return []string{"--embed-metadata", "--parse-metadata", from + `:^(?P<meta_title>.+) \((?P<meta_track>\d+/\d+)\)$`}
+}
+
+// splitChapters downloads the whole video and has yt-dlp split it into one file per chapter.
+// A cue sheet needs a file type cue players know, so the audio is extracted as FLAC for one.
+func splitChapters(job *Job, info *VideoInfo) error {
+	path := "bin/yt-dlp"
+	if isWindows() {
+		path = "bin/yt-dlp.exe"
+	}
+
+	logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("Error creating log file: %v", err)
+	}
+	defer logFile.Close()
+
+	if job.Chapters.Cue && job.Kind != JobAudio {
+		return fmt.Errorf("Cue sheets can only be written when splitting audio")
+	}
+
+	var outBuf, errBuf strings.Builder
+
+	dir := chapterDir(info)
+	base := utils.SanitizeFilename(info.Title)
+	args := chapterArgs(job, logFile)
+	if job.Chapters.Cue {
+		args = append(args, "--audio-format", "flac")
+	}
+	args = append(args, "--split-chapters", "--embed-metadata")
+	args = append(args, "--sleep-requests", "1", "--sleep-interval", "5", "--max-sleep-interval", "10", "--newline")
+	args = append(args, "--print", "after_move:filepath", "--no-quiet")
+	args = append(args, "-o", filepath.Join(templateEscape(dir), templateEscape(base)+".%(ext)s"))
+	args = append(args, "-o", "chapter:"+filepath.Join(templateEscape(dir), "%(section_number)02d - %(section_title)s.%(ext)s"), job.URL)
+
+	cmd := job.command(path, args...)
+	cmd.Stdout = io.MultiWriter(&outBuf, logFile, job.progressWriter())
+	cmd.Stderr = io.MultiWriter(&errBuf, logFile)
+	err = cmd.Run()
+	if err != nil {
+		return ytdlpError("splitting chapters", err, errBuf.String())
+	}
+
+	files := splitChapterFiles(outBuf.String())
+	if err := tagChapterFiles(job, info, files, logFile); err != nil {
+		job.reportFiles(files)
+		return err
+	}
+	if job.Chapters.Cue {
+		fullFile := lastLine(outBuf.String())
+		if fullFile == "" {
+			job.reportFiles(files)
+			return fmt.Errorf("Chapters were split but the cue sheet could not be written: unknown output file")
+		}
+		cue, err := BuildCueSheet(info, filepath.Base(fullFile))
+		if err != nil {
+			job.reportFiles(files)
+			return err
+		}
+		cuePath := filepath.Join(dir, base+".cue")
+		if err := os.WriteFile(cuePath, []byte(cue), 0644); err != nil {
+			job.reportFiles(files)
+			return fmt.Errorf("Error writing cue sheet: %v", err)
+		}
+		files = append(files, fullFile, cuePath)
+	}
+
+	job.reportFiles(files)
+	return nil
+}
+
+// tagChapterFiles writes the title and track number n/N of each chapter into the files split
+// from the video, which yt-dlp tags with the title of the whole video. The files come in the
+// order of the chapters.
+func tagChapterFiles(job *Job, info *VideoInfo, files []string, logFile io.Writer) error {
+	ffmpegPath := "bin/ffmpeg"
+	if isWindows() {
+		ffmpegPath = "bin/ffmpeg.exe"
+	}
+	if _, err := os.Stat(ffmpegPath); err != nil {
+		if ffmpegPath, err = exec.LookPath("ffmpeg"); err != nil {
+			return fmt.Errorf("Chapters were split but could not be tagged: ffmpeg not found")
+		}
+	}
+
+	ctx := job.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for i, file := range files {
+		if i >= len(info.Chapters) {
+			break
+		}
+		title := info.Chapters[i].Title
+		ext := filepath.Ext(file)
+		tagged := strings.TrimSuffix(file, ext) + ".tagged" + ext
+		cmd := exec.CommandContext(ctx, ffmpegPath, "-y", "-loglevel", "error", "-i", file, "-map", "0", "-c", "copy",
+			"-metadata", "title="+title, "-metadata", fmt.Sprintf("track=%d/%d", i+1, len(info.Chapters)), tagged)
+		cmd.Stdout = logFile
+		cmd.Stderr = logFile
+		if err := cmd.Run(); err != nil {
+			os.Remove(tagged)
+			return fmt.Errorf("Error tagging chapter %q: %v. Check output.log for details.", title, err)
+		}
+		if err := os.Rename(tagged, file); err != nil {
+			os.Remove(tagged)
+			return fmt.Errorf("Error tagging chapter %q: %v", title, err)
+		}
+	}
+	return nil
+}
+
+var splitChapterRe = regexp.MustCompile(`^\[SplitChapters\] Chapter \d+; Destination: (.+)$`)
+
+// splitChapterFiles are the chapter files yt-dlp said it wrote while splitting
+func splitChapterFiles(output string) []string {
+	var files []string
+	for _, line := range strings.Split(output, "\n") {
+		if matches := splitChapterRe.FindStringSubmatch(strings.TrimSpace(line)); matches != nil {
+			files = append(files, matches[1])
+		}
+	}
+	return files
+}
+
+// cueFileType is the FILE type of a cue sheet for an audio file, cue sheets know no others
+func cueFileType(file string) (string, bool) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".mp3":
+		return "MP3", true
+	case ".aif", ".aiff":
+		return "AIFF", true
+	case ".wav", ".flac":
+		return "WAVE", true
+	}
+	return "", false
+}
+
+func BuildCueSheet(info *VideoInfo, file string) (string, error) {
+	fileType, ok := cueFileType(file)
+	if !ok {
+		return "", fmt.Errorf("Cue sheets can't point to %s files", filepath.Ext(file))
+	}
+
+	var s strings.Builder
+
+	fmt.Fprintf(&s, "PERFORMER \"%s\"\n", cueEscape(info.Author()))
+	fmt.Fprintf(&s, "TITLE \"%s\"\n", cueEscape(info.Title))
+	fmt.Fprintf(&s, "FILE \"%s\" %s\n", cueEscape(file), fileType)
+
+	for i, chapter := range info.Chapters {
+		fmt.Fprintf(&s, "  TRACK %02d AUDIO\n", i+1)
+		fmt.Fprintf(&s, "    TITLE \"%s\"\n", cueEscape(chapter.Title))
+		fmt.Fprintf(&s, "    PERFORMER \"%s\"\n", cueEscape(info.Author()))
+		fmt.Fprintf(&s, "    INDEX 01 %s\n", cueTimestamp(chapter.StartTime))
+	}
+
+	return s.String(), nil
+}
+
+// cue sheets have no escaping, so double quotes are swapped for single ones
+func cueEscape(s string) string {
+	return strings.ReplaceAll(s, `"`, "'")
+}
+
+// cue timestamps are mm:ss:ff with 75 frames per second
+func cueTimestamp(seconds float64) string {
+	frames := int(seconds*75 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d", frames/(75*60), (frames/75)%60, frames%75)
+}
+
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line != "" && !strings.HasPrefix(line, "[") && !strings.HasPrefix(line, "WARNING") && !strings.HasPrefix(line, titlePrefix) {
+			return line
+		}
+	}
+	return ""
+}
+
+type ChapterDownloadMsg struct {
+	JobID int
+	Dir   string
+	Files []string
+	Error string
+}
@@ -0,0 +1,373 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/utils"
+)
+
+// The demo backend answers from the canned yt-dlp output below instead of running yt-dlp, so
+// preview.tape records the same GIF on every run without a network. Fetches and downloads take
+// fixed times, and any video other than the demo ones fails the way yt-dlp does.
+const (
+	demoVideoID    = "jNQXAC9IVRw"
+	demoPrivateID  = "priv4teDemo"
+	demoLiveID     = "l1veZooCam0"
+	demoPremiereID = "prem1ereZoo"
+	demoFetchTime  = 800 * time.Millisecond
+	demoStepTime   = 300 * time.Millisecond
+	// demoPremiereWait is how long after the demo starts the premiere begins
+	demoPremiereWait = 20 * time.Second
+	// demoLiveRate is how fast a demo recording grows, in bytes per step
+	demoLiveRate = 96 << 10
+)
+
+const demoInfoJSON = `{
+	"id": "jNQXAC9IVRw",
+	"title": "Me at the zoo",
+	"channel": "jawed",
+	"duration": 19,
+	"upload_date": "20050424",
+	"view_count": 348201447,
+	"live_status": "not_live",
+	"webpage_url": "https://www.youtube.com/watch?v=jNQXAC9IVRw",
+	"chapters": [
+		{"title": "Elephants", "start_time": 0, "end_time": 7},
+		{"title": "Really long trunks", "start_time": 7, "end_time": 14},
+		{"title": "That's pretty much it", "start_time": 14, "end_time": 19}
+	],
+	"thumbnails": [
+		{"url": "https://i.ytimg.com/vi/jNQXAC9IVRw/default.jpg", "width": 120, "height": 90, "preference": -11},
+		{"url": "https://i.ytimg.com/vi/jNQXAC9IVRw/hqdefault.jpg", "width": 480, "height": 360, "preference": -7},
+		{"url": "https://i.ytimg.com/vi_webp/jNQXAC9IVRw/maxresdefault.webp", "width": 1280, "height": 720, "preference": 0}
+	],
+	"subtitles": {"en": [], "de": []},
+	"automatic_captions": {"en": [], "fr": [], "es": [], "ja": []}
+}`
+
+const demoExtractorList = `bandcamp
+bandcamp:album
+dailymotion
+generic
+soundcloud
+soundcloud:set
+twitch:vod
+twitter
+vimeo
+vimeo:album
+youtube
+youtube:tab
+`
+
+const demoSearchJSON = `{
+	"_type": "playlist",
+	"entries": [
+		{"id": "jNQXAC9IVRw", "title": "Me at the zoo", "channel": "jawed", "duration": 19, "view_count": 348201447},
+		{"id": "UCzoo4demo1", "title": "Zoo tour, all the animals in one day", "channel": "Zoo Channel", "duration": 1385, "view_count": 84210},
+		{"id": "elephant_01", "title": "Elephants have really long trunks", "channel": "Nature Clips", "duration": 242, "view_count": 1203344},
+		{"id": "UC_channel_demo_zoo", "title": "Zoo Channel", "channel": "Zoo Channel"}
+	]
+}`
+
+const demoFormatList = `[youtube] jNQXAC9IVRw: Downloading webpage
+[info] Available formats for jNQXAC9IVRw:
+ID  EXT   RESOLUTION FPS CH |   FILESIZE   TBR PROTO | VCODEC          VBR ACODEC      ABR ASR MORE INFO
+-----------------------------------------------------------------------------------------------------------------
+139 m4a   audio only      2 |  119.21KiB   49k https | audio only          mp4a.40.5   49k 22k [en] low, m4a_dash
+249 webm  audio only      2 |  113.82KiB   47k https | audio only          opus        47k 48k [en] low, webm_dash
+140 m4a   audio only      2 |  307.96KiB  129k https | audio only          mp4a.40.2  129k 44k [en] medium, m4a_dash
+251 webm  audio only      2 |  242.58KiB  100k https | audio only          opus       100k 48k [en] medium, webm_dash
+160 mp4   256x144     15    |   68.71KiB   28k https | avc1.4d400b     28k video only          144p, mp4_dash
+133 mp4   426x240     30    |  149.37KiB   62k https | avc1.4d400c     62k video only          240p, mp4_dash
+134 mp4   640x360     30    |  332.55KiB  139k https | avc1.4d401e    139k video only          360p, mp4_dash
+135 mp4   854x480     30    |  612.08KiB  255k https | avc1.4d401e    255k video only          480p, mp4_dash
+136 mp4   1280x720    30    |    1.20MiB  508k https | avc1.4d401f    508k video only          720p, mp4_dash
+137 mp4   1920x1080   30    |    2.31MiB  980k https | avc1.640028    980k video only          1080p, mp4_dash
+`
+
+const demoSubtitleList = `[youtube] jNQXAC9IVRw: Downloading webpage
+[info] Available automatic captions for jNQXAC9IVRw:
+Language Name      Formats
+en       English   vtt, ttml, srv3, srv2, srv1, json3
+fr       French    vtt, ttml, srv3, srv2, srv1, json3
+de       German    vtt, ttml, srv3, srv2, srv1, json3
+es       Spanish   vtt, ttml, srv3, srv2, srv1, json3
+ja       Japanese  vtt, ttml, srv3, srv2, srv1, json3
+`
+
+type demoBackend struct {
+	installed atomic.Bool
+	premiere  time.Time
+}
+
+func NewDemoBackend() Backend {
+	return &demoBackend{premiere: time.Now().Add(demoPremiereWait)}
+}
+
+// the installer prompt is part of the demo, so yt-dlp only counts as installed once "installed"
+func (d *demoBackend) YtdlpInstalled() bool {
+	return d.installed.Load()
+}
+
+func (d *demoBackend) InstallYtdlp() error {
+	time.Sleep(2 * demoFetchTime)
+	d.installed.Store(true)
+	return nil
+}
+
+func (d *demoBackend) Extractors() ([]string, error) {
+	return parseExtractors(demoExtractorList), nil
+}
+
+func (d *demoBackend) VideoInfo(url string) (*VideoInfo, error) {
+	if err := demoLookup(url, "fetching video info"); err != nil {
+		return nil, err
+	}
+	var info VideoInfo
+	if err := json.Unmarshal([]byte(demoInfoJSON), &info); err != nil {
+		return nil, fmt.Errorf("Error reading video info: %v", err)
+	}
+	// the streams are the demo video dressed up as one, without chapters or a length yet
+	parsed, _ := ParseVideoURL(url)
+	switch parsed.ID {
+	case demoLiveID:
+		info.ID, info.Title, info.LiveStatus = demoLiveID, demoTitle(demoLiveID), "is_live"
+	case demoPremiereID:
+		info.ID, info.Title, info.LiveStatus = demoPremiereID, demoTitle(demoPremiereID), "is_upcoming"
+		info.ReleaseTimestamp = d.premiere.Unix()
+	default:
+		return &info, nil
+	}
+	info.WebpageURL = parsed.String()
+	info.Duration, info.Chapters = 0, nil
+	return &info, nil
+}
+
+func (d *demoBackend) AudioFormats(url string) ([]AudioFormat, error) {
+	if err := demoLookup(url, "fetching formats"); err != nil {
+		return nil, err
+	}
+	return ParseAudioFormats(demoFormatList), nil
+}
+
+func (d *demoBackend) VideoFormats(url string) ([]VideoFormat, error) {
+	if err := demoLookup(url, "fetching formats"); err != nil {
+		return nil, err
+	}
+	return ParseVideoFormats(demoFormatList), nil
+}
+
+func (d *demoBackend) SubtitleLanguages(url string) ([]SubtitleLanguage, error) {
+	if err := demoLookup(url, "fetching subtitle languages"); err != nil {
+		return nil, err
+	}
+	return ParseSubtitleLanguages(demoSubtitleList), nil
+}
+
+// SponsorSegments has the demo video thank its sponsor, other videos have no segments
+func (d *demoBackend) SponsorSegments(videoID string) ([]SponsorSegment, error) {
+	time.Sleep(demoFetchTime)
+	if videoID != demoVideoID {
+		return nil, nil
+	}
+	return []SponsorSegment{
+		{Category: "sponsor", Segment: [2]float64{1.5, 5}},
+		{Category: "interaction", Segment: [2]float64{16, 19}},
+	}, nil
+}
+
+// Search finds the demo video for any keywords, the other results fail as unavailable
+func (d *demoBackend) Search(query string, limit int) ([]SearchResult, error) {
+	time.Sleep(demoFetchTime)
+	results, err := parseSearchResults(demoSearchJSON)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, err
+}
+
+func (d *demoBackend) Run(job *Job) error {
+	if err := demoLookup(job.URL, "downloading "+string(job.Kind)); err != nil {
+		return err
+	}
+
+	ctx := job.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if info, err := d.VideoInfo(job.URL); err == nil {
+		job.reportTitle(info.Title)
+	}
+	if job.Live != "" {
+		return d.record(ctx, job)
+	}
+	for percent := 10; percent <= 100; percent += 10 {
+		if err := demoSleep(ctx, demoStepTime); err != nil {
+			return err
+		}
+		if job.onProgress != nil {
+			job.onProgress(float64(percent))
+		}
+	}
+	job.reportFiles(demoJobFiles(job))
+	return nil
+}
+
+// record waits for the premiere and then grows a recording until it's stopped
+func (d *demoBackend) record(ctx context.Context, job *Job) error {
+	if parsed, _ := ParseVideoURL(job.URL); parsed.ID == demoPremiereID {
+		if err := demoSleep(ctx, time.Until(d.premiere)); err != nil {
+			return err
+		}
+	}
+	var size int64
+	for !job.Stopping() {
+		if err := demoSleep(ctx, demoStepTime); err != nil {
+			return err
+		}
+		size += demoLiveRate
+		job.reportRecorded(size)
+	}
+	if err := demoSleep(ctx, demoFetchTime); err != nil {
+		return err
+	}
+	job.reportFiles([]string{demoJobFile(job)})
+	return nil
+}
+
+// demoJobFiles are the files of a job, one for each chapter of chapter jobs
+func demoJobFiles(job *Job) []string {
+	if job.Chapters == nil {
+		return []string{demoJobFile(job)}
+	}
+	info := job.Chapters.info
+	indices := job.Chapters.Indices
+	if job.Chapters.Split {
+		indices = nil
+		for idx := range info.Chapters {
+			indices = append(indices, idx)
+		}
+	}
+	var files []string
+	for _, idx := range indices {
+		files = append(files, demoChapterFile(info, idx, job.Kind == JobAudio))
+	}
+	if job.Chapters.Cue {
+		base := filepath.Join(chapterDir(info), utils.SanitizeFilename(info.Title))
+		files = append(files, base+".flac", base+".cue")
+	}
+	return files
+}
+
+// demoJobFile is where the real backend would have saved a job, nothing is written
+func demoJobFile(job *Job) string {
+	if job.Live != "" {
+		return demoOutputName(job) + ".ts"
+	}
+	switch job.Kind {
+	case JobAudio:
+		return demoOutputName(job) + ".m4a"
+	case JobSubtitles:
+		lang := job.Lang
+		if lang == "" {
+			lang = "en"
+		}
+		return demoOutputName(job) + "." + lang + ".vtt"
+	default:
+		return demoOutputName(job) + ".mp4"
+	}
+}
+
+// demoTitle is the title of the demo video with the given ID
+func demoTitle(id string) string {
+	switch id {
+	case demoLiveID:
+		return "Zoo cam, the elephants live"
+	case demoPremiereID:
+		return "Me at the zoo, the sequel"
+	default:
+		return "Me at the zoo"
+	}
+}
+
+// demoOutputName is the output name of a job with the template filled in for the demo video
+func demoOutputName(job *Job) string {
+	name := job.OutputName(outputPath(defaultOutputName))
+	parsed, err := ParseVideoURL(job.URL)
+	if err != nil {
+		return name
+	}
+	return strings.NewReplacer("%(title)s", demoTitle(parsed.ID), "%(id)s", parsed.ID).Replace(name)
+}
+
+func (d *demoBackend) SaveThumbnail(thumb Thumbnail, convertTo string) (string, error) {
+	time.Sleep(demoFetchTime)
+	ext := thumb.Ext()
+	if convertTo != "" {
+		ext = convertTo
+	}
+	return thumbnailName(thumb) + "." + ext, nil
+}
+
+func demoChapterFile(info *VideoInfo, idx int, audio bool) string {
+	ext := "mp4"
+	if audio {
+		ext = "m4a"
+	}
+	name := fmt.Sprintf("%02d - %s.%s", idx+1, utils.SanitizeFilename(info.Chapters[idx].Title), ext)
+	return filepath.Join(chapterDir(info), name)
+}
+
+// demoLookup waits like a real request would and fails for anything but the demo video,
+// with the same messages yt-dlp's failures produce
+func demoLookup(url string, action string) error {
+	time.Sleep(demoFetchTime)
+
+	parsed, err := ParseVideoURL(url)
+	if err != nil {
+		return err
+	}
+
+	var stderr string
+	switch {
+	case parsed.Raw != "":
+		stderr = "ERROR: Unsupported URL: " + parsed.Raw
+	case parsed.ID == demoVideoID || parsed.ID == demoLiveID || parsed.ID == demoPremiereID:
+		return nil
+	case parsed.ID == demoPrivateID:
+		stderr = "ERROR: [youtube] " + parsed.ID + ": Private video. Sign in if you've been granted access to this video"
+	default:
+		stderr = "ERROR: [youtube] " + parsed.ID + ": Video unavailable. This video is not available"
+	}
+	return ytdlpError(action, errors.New("exit status 1"), stderr)
+}
+
+func demoSleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// ExtractDemoFlag pulls --demo out of args, so it works before any subcommand like --profile
+func ExtractDemoFlag(args []string) (bool, []string) {
+	demo := false
+	var rest []string
+	for _, arg := range args {
+		if arg == "--demo" || arg == "-demo" {
+			demo = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return demo, rest
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/config"
+	"github.com/AbdelilahOu/Bubly-cli-app/utils"
+)
+
+const (
+	// lowDiskSpace gets a warning for downloads of unknown size
+	lowDiskSpace = 1 << 30
+	// minDiskSpace stops a running download, yt-dlp would fail soon after anyway
+	minDiskSpace = 64 << 20
+	// diskCheckInterval is how often the space is checked again while a download runs
+	diskCheckInterval = 30 * time.Second
+)
+
+// SpaceNeeded estimates the disk space a job takes while it runs, zero when its size is
+// unknown. yt-dlp keeps the downloaded streams until the merged, extracted or cut file is
+// written, so those need room for both. Cutting out sponsors writes a copy too.
+func (j *Job) SpaceNeeded() int64 {
+	if j.Size <= 0 {
+		return 0
+	}
+	format := j.Format
+	if format == "" && j.Kind == JobVideo {
+		format = config.Current().VideoFormatFor(DetectSite(j.URL).Name)
+	}
+	overhead := 1.1
+	if j.Kind == JobAudio || j.Clip != nil || strings.Contains(format, "+") || sponsorBlockMode(j) == "remove" {
+		overhead = 2.1
+	}
+	return int64(float64(j.Size) * overhead)
+}
+
+// estimateSize is the size of a format as shown in the pickers, cut down to the clip when
+// the video's duration is known
+func estimateSize(filesize string, clip *TimeRange, info *VideoInfo) int64 {
+	size, ok := utils.ParseSize(filesize)
+	if !ok || clip == nil || info == nil || info.Duration <= 0 {
+		return size
+	}
+	end := info.Duration
+	if clip.HasEnd && clip.End < end {
+		end = clip.End
+	}
+	if end <= clip.Start {
+		return size
+	}
+	return int64(float64(size) * (end - clip.Start) / info.Duration)
+}
+
+// jobDir is the folder a job downloads into
+func jobDir(job *Job) string {
+	if job.Output != "" {
+		return filepath.Dir(job.Output)
+	}
+	return outputPath()
+}
+
+// closestDir is dir, or its closest parent that exists when dir wasn't created yet
+func closestDir(dir string) string {
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// checkDiskSpace is the preflight of a job, reserved is the space other running jobs still
+// need. It returns a warning to show, or an error when the job shouldn't start. When the free
+// space can't be found out the job just runs.
+func checkDiskSpace(job *Job, reserved int64) (string, error) {
+	mode := config.Current().DiskCheck
+	if mode == "off" {
+		return "", nil
+	}
+	dir := jobDir(job)
+	free, err := utils.FreeSpace(closestDir(dir))
+	if err != nil {
+		return "", nil
+	}
+	free -= reserved
+	if free < 0 {
+		free = 0
+	}
+
+	need := job.SpaceNeeded()
+	switch {
+	case need > 0 && free < need:
+		problem := fmt.Sprintf("Not enough disk space: the download needs about %s while it runs, but only %s is free in %s", utils.FormatSize(need), utils.FormatSize(free), dir)
+		if mode == "warn" {
+			return problem, nil
+		}
+		return "", errors.New(problem + ". Free some space or pick another output folder in Settings.")
+	case need == 0 && free < lowDiskSpace:
+		return fmt.Sprintf("Only %s is free in %s, the download may not fit", utils.FormatSize(free), dir), nil
+	}
+	return "", nil
+}
+
+// CheckSpace is the disk space preflight of a job, counting the space the running jobs
+// still need
+func (q *Queue) CheckSpace(job *Job) (string, error) {
+	q.mu.Lock()
+	var reserved int64
+	for _, j := range q.jobs {
+		if j != job && j.Status == JobRunning {
+			reserved += int64(float64(j.SpaceNeeded()) * (100 - j.Progress) / 100)
+		}
+	}
+	q.mu.Unlock()
+	return checkDiskSpace(job, reserved)
+}
+
+// watchSpace stops a running job when the disk is about to fill up. Queues can run for hours,
+// so the space at the start says little about the space at the end.
+func (q *Queue) watchSpace(job *Job) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(diskCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if mode := config.Current().DiskCheck; mode != "" && mode != "block" {
+				continue
+			}
+			dir := jobDir(job)
+			free, err := utils.FreeSpace(closestDir(dir))
+			if err != nil || free >= minDiskSpace {
+				continue
+			}
+			q.mu.Lock()
+			job.stopErr = fmt.Sprintf("Stopped because the disk is almost full, only %s is free in %s", utils.FormatSize(free), dir)
+			q.mu.Unlock()
+			job.cancel()
+			return
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,286 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/utils"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ResultFile is a file a download produced. Size is -1 once the file is gone and Duration
+// is 0 when it isn't known, or the file isn't audio or video.
+type ResultFile struct {
+	Path     string
+	Size     int64
+	Duration float64
+}
+
+var mediaExts = map[string]bool{
+	".mp4": true, ".mkv": true, ".webm": true, ".mov": true, ".ts": true,
+	".m4a": true, ".mp3": true, ".opus": true, ".ogg": true, ".flac": true, ".wav": true, ".aac": true,
+}
+
+func isMedia(path string) bool {
+	return mediaExts[strings.ToLower(filepath.Ext(path))]
+}
+
+// statFiles finds the sizes of files, duration is used for the media files until probing
+// finds out better
+func statFiles(paths []string, duration float64) []ResultFile {
+	files := make([]ResultFile, len(paths))
+	for i, path := range paths {
+		files[i] = ResultFile{Path: path, Size: -1}
+		if info, err := os.Stat(path); err == nil {
+			files[i].Size = info.Size()
+		}
+		if isMedia(path) {
+			files[i].Duration = duration
+		}
+	}
+	return files
+}
+
+func ffprobePath() string {
+	path := "bin/ffprobe"
+	if isWindows() {
+		path = "bin/ffprobe.exe"
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	if path, err := exec.LookPath("ffprobe"); err == nil {
+		return path
+	}
+	return ""
+}
+
+// probeDuration asks ffprobe for the length of a media file, 0 when there's no ffprobe
+func probeDuration(ffprobe, path string) float64 {
+	out, err := exec.Command(ffprobe, "-v", "error", "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", path).Output()
+	if err != nil {
+		return 0
+	}
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0
+	}
+	return duration
+}
+
+type filesProbedMsg struct {
+	screen    *filesScreen
+	durations map[string]float64
+}
+
+// filesScreen is shown once a download is done, with the files it produced and what can be
+// done with them
+type filesScreen struct {
+	layout  *Layout
+	heading string
+	files   []ResultFile
+	choice  int
+	// deleting is set after the first press of delete, which needs a second one
+	deleting bool
+	status   string
+	err      string
+}
+
+func newFilesScreen(layout *Layout, heading string, paths []string, duration float64) *filesScreen {
+	return &filesScreen{layout: layout, heading: heading, files: statFiles(paths, duration)}
+}
+
+// showFiles pushes the files screen, nothing when the download didn't say what it wrote
+func showFiles(layout *Layout, heading string, paths []string, duration float64) tea.Cmd {
+	if len(paths) == 0 {
+		return nil
+	}
+	return pushScreen(newFilesScreen(layout, heading, paths, duration))
+}
+
+// clipDuration is the length of what gets downloaded of a video, 0 when it isn't known
+func clipDuration(clip *TimeRange, info *VideoInfo) float64 {
+	if info == nil || info.Duration <= 0 {
+		return 0
+	}
+	if clip == nil {
+		return info.Duration
+	}
+	end := info.Duration
+	if clip.HasEnd && clip.End < end {
+		end = clip.End
+	}
+	if end <= clip.Start {
+		return 0
+	}
+	return end - clip.Start
+}
+
+func (s *filesScreen) Init() tea.Cmd {
+	ffprobe := ffprobePath()
+	if ffprobe == "" {
+		return nil
+	}
+	paths := make([]string, len(s.files))
+	for i, file := range s.files {
+		paths[i] = file.Path
+	}
+	return func() tea.Msg {
+		durations := map[string]float64{}
+		for _, path := range paths {
+			if isMedia(path) {
+				durations[path] = probeDuration(ffprobe, path)
+			}
+		}
+		return filesProbedMsg{screen: s, durations: durations}
+	}
+}
+
+func (s *filesScreen) Title() string {
+	return "Files"
+}
+
+func (s *filesScreen) Keys() []key.Binding {
+	if len(s.files) == 0 {
+		return nil
+	}
+	bindings := listKeys(len(s.files), s.layout.ItemsPerPage)
+	if s.deleting {
+		return append(bindings, withDesc(keys.Delete, "confirm delete"))
+	}
+	return append(bindings, keys.Open, keys.Reveal, keys.Copy, keys.Delete)
+}
+
+func (s *filesScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
+	switch msg := msg.(type) {
+	case filesProbedMsg:
+		if msg.screen != s {
+			return s, nil
+		}
+		for i, file := range s.files {
+			if duration := msg.durations[file.Path]; duration > 0 {
+				s.files[i].Duration = duration
+			}
+		}
+	case tea.KeyMsg:
+		if len(s.files) == 0 {
+			return s, nil
+		}
+		confirming := s.deleting
+		s.deleting = false
+		if movePage(msg, &s.choice, len(s.files), s.layout.ItemsPerPage) {
+			s.status, s.err = "", ""
+			return s, nil
+		}
+
+		file := s.files[s.choice]
+		name := filepath.Base(file.Path)
+		s.status, s.err = "", ""
+		switch {
+		case key.Matches(msg, keys.Open):
+			s.report("Opened "+name, utils.OpenFile(file.Path))
+		case key.Matches(msg, keys.Reveal):
+			s.report("Showing "+name+" in its folder", utils.RevealFile(file.Path))
+		case key.Matches(msg, keys.Copy):
+			path, err := filepath.Abs(file.Path)
+			if err != nil {
+				path = file.Path
+			}
+			if utils.CopyToClipboard(path) {
+				s.status = "Copied " + path
+			} else {
+				s.status = "Copied " + path + " through the terminal, which may not support it"
+			}
+		case key.Matches(msg, keys.Delete):
+			if !confirming {
+				s.deleting = true
+				s.status = "Press " + keys.Delete.Help().Key + " again to delete " + name
+				return s, nil
+			}
+			if err := os.Remove(file.Path); err != nil && !os.IsNotExist(err) {
+				s.err = err.Error()
+				return s, nil
+			}
+			s.files = append(s.files[:s.choice], s.files[s.choice+1:]...)
+			if s.choice >= len(s.files) && s.choice > 0 {
+				s.choice--
+			}
+			s.status = "Deleted " + name
+		}
+	}
+	return s, nil
+}
+
+// summary is e.g. ", 2 files, 12.3 MiB" for the heading
+func (s *filesScreen) summary() string {
+	var total int64
+	for _, file := range s.files {
+		if file.Size > 0 {
+			total += file.Size
+		}
+	}
+	count := "1 file"
+	if len(s.files) != 1 {
+		count = fmt.Sprintf("%d files", len(s.files))
+	}
+	if total == 0 {
+		return ", " + count
+	}
+	return ", " + count + ", " + utils.FormatSize(total)
+}
+
+func (s *filesScreen) report(done string, err error) {
+	if err != nil {
+		s.err = err.Error()
+		return
+	}
+	s.status = done
+}
+
+func (s *filesScreen) View() string {
+	var b strings.Builder
+	b.WriteString(SuccessStyle(s.layout.Wrap(s.heading+s.summary())) + "\n\n")
+
+	if len(s.files) == 0 {
+		b.WriteString("No files left\n")
+	}
+	start, end := pageBounds(s.choice, len(s.files), s.layout.ItemsPerPage)
+	for i := start; i < end; i++ {
+		file := s.files[i]
+		cursor := "  "
+		if s.choice == i {
+			cursor = "> "
+		}
+
+		size := "missing"
+		if file.Size >= 0 {
+			size = utils.FormatSize(file.Size)
+		}
+		line := cursor + subtitleLangStyle(filepath.Base(file.Path)) + videoFileSizeStyle(size)
+		if file.Duration > 0 {
+			line += chapterTimeStyle(utils.FormatDuration(file.Duration))
+		}
+		b.WriteString(s.layout.Truncate(line) + "\n")
+	}
+	b.WriteString(pageFooter(s.choice, len(s.files), s.layout.ItemsPerPage))
+
+	if len(s.files) > 0 {
+		dir := filepath.Dir(s.files[s.choice].Path)
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		b.WriteString("\n" + infoLabelStyle(s.layout.Truncate("In "+dir)))
+	}
+	if s.status != "" {
+		b.WriteString("\n\n" + s.layout.Truncate(s.status))
+	}
+	if s.err != "" {
+		b.WriteString("\n" + ErrorStyle(s.layout.Wrap(s.err)))
+	}
+	return b.String()
+}
@@ -0,0 +1,145 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/config"
+)
+
+// hookInput is the JSON a hook gets on stdin, Job for the job events and Jobs for "drained"
+type hookInput struct {
+	Event string        `json:"event"`
+	Job   *ReportEntry  `json:"job,omitempty"`
+	Jobs  []ReportEntry `json:"jobs,omitempty"`
+}
+
+// hookShell runs a hook command the way a terminal would
+func hookShell(ctx context.Context, command string) *exec.Cmd {
+	if isWindows() {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// runHook runs the hook of an event, if there is one, with env added to the environment and
+// input on stdin. Its output goes to output.log. Cancelling ctx or the timeout kills it.
+func runHook(ctx context.Context, event string, env []string, input hookInput) error {
+	hooks := config.Current().Hooks
+	command := hooks.Command(event)
+	if command == "" {
+		return nil
+	}
+
+	logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("Error creating log file: %v", err)
+	}
+	defer logFile.Close()
+
+	data, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hooks.TimeoutDuration())
+	defer cancel()
+
+	var errBuf strings.Builder
+	fmt.Fprintf(logFile, "Running the %s hook: %s\n", event, command)
+	cmd := hookShell(ctx, command)
+	cmd.Env = append(append(os.Environ(), "BUBLY_EVENT="+event), env...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = logFile
+	cmd.Stderr = io.MultiWriter(&errBuf, logFile)
+	// children of the shell can keep stdin open after it was killed
+	cmd.WaitDelay = 5 * time.Second
+	err = cmd.Run()
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		err = fmt.Errorf("timed out after %s", hooks.TimeoutDuration())
+	case err == nil:
+		return nil
+	case lastLine(errBuf.String()) != "":
+		err = fmt.Errorf("%v: %s", err, lastLine(errBuf.String()))
+	}
+	fmt.Fprintf(logFile, "The %s hook failed: %v\n", event, err)
+	return &JobError{Class: "hook", Message: fmt.Sprintf("The %s hook failed: %v", event, err)}
+}
+
+// jobHookEnv are the BUBLY_JOB_* variables of a job, BUBLY_JOB_FILES has one file per line
+func jobHookEnv(entry ReportEntry) []string {
+	file := ""
+	if len(entry.Files) > 0 {
+		file = entry.Files[0]
+	}
+	return []string{
+		"BUBLY_JOB_ID=" + strconv.Itoa(entry.ID),
+		"BUBLY_JOB_URL=" + entry.URL,
+		"BUBLY_JOB_TITLE=" + entry.Title,
+		"BUBLY_JOB_KIND=" + string(entry.Kind),
+		"BUBLY_JOB_FORMAT=" + entry.Format,
+		"BUBLY_JOB_STATUS=" + string(entry.Status),
+		"BUBLY_JOB_ERROR=" + entry.Error,
+		"BUBLY_JOB_ERROR_CLASS=" + entry.ErrorClass,
+		"BUBLY_JOB_FILE=" + file,
+		"BUBLY_JOB_FILES=" + strings.Join(entry.Files, "\n"),
+		"BUBLY_JOB_BYTES=" + strconv.FormatInt(entry.Bytes, 10),
+	}
+}
+
+// runJobHook runs the hook of a job event. The job is still running, so the success and
+// failure hooks get it the way it's about to finish, failure being why it failed.
+func (q *Queue) runJobHook(job *Job, event string, failure error) error {
+	if config.Current().Hooks.Command(event) == "" {
+		return nil
+	}
+
+	q.mu.Lock()
+	snapshot := *job
+	q.mu.Unlock()
+	switch event {
+	case "success":
+		snapshot.Status, snapshot.Progress, snapshot.Finished = JobDone, 100, time.Now()
+	case "failure":
+		snapshot.Status, snapshot.Finished = JobFailed, time.Now()
+		snapshot.Error, snapshot.ErrorClass = failure.Error(), errorClass(failure)
+	}
+
+	// cancelling the job kills its hooks, except the failure one of a job the queue stopped
+	ctx := job.ctx
+	if event == "failure" {
+		ctx = context.Background()
+	}
+	entry := NewReportEntry(snapshot)
+	return runHook(ctx, event, jobHookEnv(entry), hookInput{Event: event, Job: &entry})
+}
+
+// runDrainedHook runs the hook for a queue that has nothing left to run
+func runDrainedHook(jobs []Job) {
+	var done, failed int
+	for _, job := range jobs {
+		switch job.Status {
+		case JobDone:
+			done++
+		case JobFailed:
+			failed++
+		}
+	}
+	env := []string{
+		"BUBLY_JOBS=" + strconv.Itoa(len(jobs)),
+		"BUBLY_JOBS_DONE=" + strconv.Itoa(done),
+		"BUBLY_JOBS_FAILED=" + strconv.Itoa(failed),
+	}
+	runHook(context.Background(), "drained", env, hookInput{Event: "drained", Jobs: NewReport(jobs)})
+}
@@ -0,0 +1,586 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/config"
+)
+
+type JobKind string
+
+const (
+	JobVideo     JobKind = "video"
+	JobAudio     JobKind = "audio"
+	JobSubtitles JobKind = "subtitles"
+)
+
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobDone      JobStatus = "done"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// Job is one download of a queue.
+type Job struct {
+	ID   int     `json:"id"`
+	Kind JobKind `json:"kind"`
+	URL  string  `json:"url"`
+	// Title is what the job downloads, filled in from yt-dlp when it wasn't given
+	Title  string     `json:"title,omitempty"`
+	Format string     `json:"format,omitempty"`
+	Lang   string     `json:"lang,omitempty"`
+	Clip   *TimeRange `json:"clip,omitempty"`
+	Output string     `json:"output,omitempty"`
+	// Size is the expected size in bytes, 0 when unknown
+	Size     int64     `json:"size,omitempty"`
+	Status   JobStatus `json:"status"`
+	Progress float64   `json:"progress"`
+	Error    string    `json:"error,omitempty"`
+	// Warning says why the job started despite a problem, like little disk space
+	Warning string `json:"warning,omitempty"`
+	// Files are the files the job produced
+	Files []string `json:"files,omitempty"`
+	// Live is "now" or "start" for recordings of live streams, which have no progress and run
+	// until the stream ends or they are stopped
+	Live string `json:"live,omitempty"`
+	// Recorded is the size a recording has so far, RecordingSince when it began
+	Recorded       int64     `json:"recorded,omitempty"`
+	RecordingSince time.Time `json:"recording_since,omitempty"`
+	Created        time.Time `json:"created"`
+	Started        time.Time `json:"started,omitempty"`
+	Finished       time.Time `json:"finished,omitempty"`
+
+	// ErrorClass groups failures in reports, like "unavailable", "network" or "disk_space"
+	ErrorClass string `json:"error_class,omitempty"`
+	// SponsorBlock is "remove", "mark" or "off", empty for the mode of the config
+	SponsorBlock string `json:"sponsorblock,omitempty"`
+	// RateLimit caps the speed of this job below the limit of the config, like "500K"
+	RateLimit string `json:"rate_limit,omitempty"`
+	// ScheduledFor is when a queued job waiting for the schedule may start
+	ScheduledFor time.Time `json:"scheduled_for,omitempty"`
+	// Chapters makes the job download chapters of the video rather than all of it
+	Chapters *ChapterJob `json:"chapters,omitempty"`
+
+	ctx        context.Context
+	cancel     context.CancelFunc
+	done       chan struct{}
+	onProgress func(float64)
+	onFiles    func([]string)
+	onTitle    func(string)
+	onRecorded func(int64)
+	// rate is the speed the job got when it started, in bytes a second, 0 for no limit
+	rate int64
+	// stopping is closed when a recording is stopped, which keeps what was recorded
+	stopping chan struct{}
+	// stopErr is why the queue stopped the job, which then fails instead of being cancelled
+	stopErr string
+}
+
+// defaultOutputName is the yt-dlp output template of jobs without an Output, one file per
+// video so that jobs running at once don't write to the same one
+const defaultOutputName = "%(title)s [%(id)s]"
+
+// outputPath is a path in the configured output folder
+func outputPath(elem ...string) string {
+	return filepath.Join(append([]string{config.Current().OutputDir}, elem...)...)
+}
+
+// OutputName is the yt-dlp output template without the extension
+func (j *Job) OutputName(fallback string) string {
+	name := fallback
+	if j.Output != "" {
+		name = j.Output
+	}
+	if j.Clip != nil {
+		name += "_" + j.Clip.FileLabel()
+	}
+	return name
+}
+
+func (j *Job) Elapsed() time.Duration {
+	if j.Started.IsZero() {
+		return 0
+	}
+	if j.Finished.IsZero() {
+		return time.Since(j.Started)
+	}
+	return j.Finished.Sub(j.Started)
+}
+
+func (j *Job) IsFinished() bool {
+	return j.Status == JobDone || j.Status == JobFailed || j.Status == JobCancelled
+}
+
+// command is ytdlpCommand bound to the job, so cancelling the job kills yt-dlp
+func (j *Job) command(path string, args ...string) *exec.Cmd {
+	ctx := j.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return exec.CommandContext(ctx, path, append(authArgs(), args...)...)
+}
+
+// progressWriter feeds yt-dlp's "[download]  42.0% of ..." lines into the job's progress
+func (j *Job) progressWriter() io.Writer {
+	return &progressWriter{report: j.onProgress}
+}
+
+// reportRecorded records the size of a recording so far
+func (j *Job) reportRecorded(size int64) {
+	if j.onRecorded != nil {
+		j.onRecorded(size)
+	}
+}
+
+// Stopping reports whether a recording was asked to stop
+func (j *Job) Stopping() bool {
+	select {
+	case <-j.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// reportTitle records the title of what the job downloads, when it wasn't known when it was added
+func (j *Job) reportTitle(title string) {
+	if j.onTitle != nil && title != "" {
+		j.onTitle(title)
+	}
+}
+
+// reportFiles records the files the job produced
+func (j *Job) reportFiles(files []string) {
+	if j.onFiles != nil && len(files) > 0 {
+		j.onFiles(files)
+	}
+}
+
+var progressRe = regexp.MustCompile(`\[download\]\s+([\d.]+)%`)
+
+type progressWriter struct {
+	report func(float64)
+	line   strings.Builder
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b == '\r' || b == '\n' {
+			w.flush()
+			continue
+		}
+		w.line.WriteByte(b)
+	}
+	return len(p), nil
+}
+
+func (w *progressWriter) flush() {
+	line := w.line.String()
+	w.line.Reset()
+	if w.report == nil {
+		return
+	}
+	if matches := progressRe.FindStringSubmatch(line); matches != nil {
+		if percent, err := strconv.ParseFloat(matches[1], 64); err == nil {
+			w.report(percent)
+		}
+	}
+}
+
+func ParseJobKind(s string) (JobKind, error) {
+	switch JobKind(s) {
+	case JobVideo, JobAudio, JobSubtitles:
+		return JobKind(s), nil
+	}
+	return "", fmt.Errorf("unknown kind %q, use video, audio or subtitles", s)
+}
+
+func RunJob(job *Job) error {
+	return backend.Run(job)
+}
+
+type Queue struct {
+	mu          sync.Mutex
+	jobs        []*Job
+	nextID      int
+	pending     chan *Job
+	wg          sync.WaitGroup
+	subscribers map[chan Job]bool
+	workers     int
+	// drainedUpTo is the number of jobs the drained hook has been run for
+	drainedUpTo int
+	OnUpdate    func(Job)
+}
+
+var (
+	sharedQueue     *Queue
+	sharedQueueOnce sync.Once
+)
+
+// SharedQueue is the queue the TUI and the HTTP API submit their downloads to
+func SharedQueue() *Queue {
+	sharedQueueOnce.Do(func() {
+		sharedQueue = NewQueue(config.Current().Concurrency)
+	})
+	return sharedQueue
+}
+
+func NewQueue(concurrency int) *Queue {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	q := &Queue{
+		pending:     make(chan *Job, 1024),
+		subscribers: map[chan Job]bool{},
+	}
+	q.Grow(concurrency)
+	return q
+}
+
+// Grow starts workers until the queue runs n jobs at once, it never stops any
+func (q *Queue) Grow(n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for ; q.workers < n; q.workers++ {
+		go q.worker()
+	}
+}
+
+func (q *Queue) Add(job *Job) Job {
+	q.mu.Lock()
+	q.nextID++
+	job.ID = q.nextID
+	job.Status = JobQueued
+	job.Created = time.Now()
+	job.ctx, job.cancel = context.WithCancel(context.Background())
+	job.done = make(chan struct{})
+	job.stopping = make(chan struct{})
+	if job.Live == "" {
+		job.ScheduledFor = nextWindow(time.Now(), config.Current().Windows())
+	}
+	q.jobs = append(q.jobs, job)
+	snapshot := *job
+	q.mu.Unlock()
+
+	q.wg.Add(1)
+	q.notify(snapshot)
+	q.pending <- job
+	return snapshot
+}
+
+// Wait blocks until every job added so far has finished
+func (q *Queue) Wait() {
+	q.wg.Wait()
+}
+
+// WaitFor blocks until the job has finished and returns its final state
+func (q *Queue) WaitFor(id int) (Job, bool) {
+	q.mu.Lock()
+	var done chan struct{}
+	for _, job := range q.jobs {
+		if job.ID == id {
+			done = job.done
+		}
+	}
+	q.mu.Unlock()
+
+	if done == nil {
+		return Job{}, false
+	}
+	<-done
+	return q.Get(id)
+}
+
+func (q *Queue) Cancel(id int) (Job, error) {
+	q.mu.Lock()
+	var target *Job
+	for _, job := range q.jobs {
+		if job.ID == id {
+			target = job
+		}
+	}
+	if target == nil {
+		q.mu.Unlock()
+		return Job{}, fmt.Errorf("no job with id %d", id)
+	}
+	if target.IsFinished() {
+		snapshot := *target
+		q.mu.Unlock()
+		return snapshot, fmt.Errorf("job %d already %s", id, target.Status)
+	}
+	target.cancel()
+	queued := target.Status == JobQueued
+	q.mu.Unlock()
+
+	// a queued job is marked right away, a running one once yt-dlp has exited
+	if queued {
+		q.finish(target, JobCancelled, "cancelled")
+	}
+	job, _ := q.Get(id)
+	return job, nil
+}
+
+// Stop ends a recording the way pressing ctrl+c in yt-dlp would, so what was recorded is
+// written out as a playable file and the job is done rather than cancelled
+func (q *Queue) Stop(id int) (Job, error) {
+	q.mu.Lock()
+	var target *Job
+	for _, job := range q.jobs {
+		if job.ID == id {
+			target = job
+		}
+	}
+	if target == nil {
+		q.mu.Unlock()
+		return Job{}, fmt.Errorf("no job with id %d", id)
+	}
+	if target.Live == "" || target.Status != JobRunning {
+		snapshot := *target
+		q.mu.Unlock()
+		return snapshot, fmt.Errorf("job %d is not a running recording", id)
+	}
+	// with nothing recorded yet there's nothing to keep
+	if target.RecordingSince.IsZero() {
+		q.mu.Unlock()
+		return q.Cancel(id)
+	}
+	if !target.Stopping() {
+		close(target.stopping)
+	}
+	snapshot := *target
+	q.mu.Unlock()
+	return snapshot, nil
+}
+
+func (q *Queue) Jobs() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]Job, len(q.jobs))
+	for i, job := range q.jobs {
+		jobs[i] = *job
+	}
+	return jobs
+}
+
+func (q *Queue) Get(id int) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, job := range q.jobs {
+		if job.ID == id {
+			return *job, true
+		}
+	}
+	return Job{}, false
+}
+
+// Subscribe returns a channel receiving every job update until unsubscribe is called.
+// Slow subscribers miss updates rather than blocking the workers.
+func (q *Queue) Subscribe() (<-chan Job, func()) {
+	ch := make(chan Job, 64)
+	q.mu.Lock()
+	q.subscribers[ch] = true
+	q.mu.Unlock()
+
+	return ch, func() {
+		q.mu.Lock()
+		if q.subscribers[ch] {
+			delete(q.subscribers, ch)
+			close(ch)
+		}
+		q.mu.Unlock()
+	}
+}
+
+func (q *Queue) worker() {
+	for job := range q.pending {
+		q.mu.Lock()
+		finished := job.IsFinished()
+		q.mu.Unlock()
+		if finished || !q.waitForSchedule(job) {
+			continue
+		}
+
+		warning, err := q.CheckSpace(job)
+		if err != nil {
+			q.failJob(job, &JobError{Class: "disk_space", Message: err.Error()})
+			continue
+		}
+
+		q.mu.Lock()
+		if job.IsFinished() {
+			q.mu.Unlock()
+			continue
+		}
+		job.Status = JobRunning
+		job.Started = time.Now()
+		job.Warning = warning
+		job.ScheduledFor = time.Time{}
+		job.rate = rateLimit(job, q.workers)
+		// set under the lock like every other field, Jobs and Get copy them
+		job.onProgress = func(percent float64) {
+			q.update(job, func(j *Job) {
+				j.Progress = percent
+			})
+		}
+		job.onFiles = func(files []string) {
+			q.update(job, func(j *Job) {
+				j.Files = files
+			})
+		}
+		job.onTitle = func(title string) {
+			q.update(job, func(j *Job) {
+				if j.Title == "" {
+					j.Title = title
+				}
+			})
+		}
+		job.onRecorded = func(size int64) {
+			q.update(job, func(j *Job) {
+				if j.RecordingSince.IsZero() && size > 0 {
+					j.RecordingSince = time.Now()
+				}
+				j.Recorded = size
+			})
+		}
+		snapshot := *job
+		q.mu.Unlock()
+
+		q.notify(snapshot)
+		stopWatching := q.watchSpace(job)
+		err = q.runJobHook(job, "start", nil)
+		if err == nil {
+			err = RunJob(job)
+		}
+		stopWatching()
+
+		q.mu.Lock()
+		stopErr := job.stopErr
+		q.mu.Unlock()
+
+		switch {
+		case stopErr != "":
+			err = &JobError{Class: "disk_space", Message: stopErr}
+		case job.ctx.Err() != nil:
+			q.finish(job, JobCancelled, "cancelled")
+			continue
+		case err == nil:
+			err = q.runJobHook(job, "success", nil)
+		}
+		if err != nil && stopErr == "" && job.ctx.Err() != nil {
+			// cancelled while the success hook ran
+			q.finish(job, JobCancelled, "cancelled")
+			continue
+		}
+		if err != nil {
+			q.failJob(job, err)
+			continue
+		}
+		q.finish(job, JobDone, "")
+	}
+}
+
+// failJob runs the failure hook and fails the job, a failing hook adds to the error
+func (q *Queue) failJob(job *Job, err error) {
+	msg := err.Error()
+	if hookErr := q.runJobHook(job, "failure", err); hookErr != nil {
+		msg += " " + hookErr.Error()
+	}
+	q.fail(job, errorClass(err), msg)
+}
+
+// fail finishes a job as failed with the class of its error
+func (q *Queue) fail(job *Job, class string, errMsg string) {
+	q.mu.Lock()
+	if !job.IsFinished() {
+		job.ErrorClass = class
+	}
+	q.mu.Unlock()
+	q.finish(job, JobFailed, errMsg)
+}
+
+func (q *Queue) finish(job *Job, status JobStatus, errMsg string) {
+	q.mu.Lock()
+	if job.IsFinished() {
+		q.mu.Unlock()
+		return
+	}
+	job.Status = status
+	job.Error = errMsg
+	if status == JobCancelled {
+		job.ErrorClass = "cancelled"
+	}
+	job.Finished = time.Now()
+	if status == JobDone {
+		job.Progress = 100
+	}
+	job.cancel()
+	close(job.done)
+	snapshot := *job
+	drained := q.drained()
+	// counted before this job is done, so that Wait waits for the hook too
+	if len(drained) > 0 && config.Current().Hooks.OnDrained != "" {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			runDrainedHook(drained)
+		}()
+	}
+	q.mu.Unlock()
+
+	q.notify(snapshot)
+	q.wg.Done()
+}
+
+// drained returns the jobs since it last did once none of them is left to run, q.mu is held
+func (q *Queue) drained() []Job {
+	for _, job := range q.jobs[q.drainedUpTo:] {
+		if !job.IsFinished() {
+			return nil
+		}
+	}
+	jobs := make([]Job, 0, len(q.jobs)-q.drainedUpTo)
+	for _, job := range q.jobs[q.drainedUpTo:] {
+		jobs = append(jobs, *job)
+	}
+	q.drainedUpTo = len(q.jobs)
+	return jobs
+}
+
+func (q *Queue) update(job *Job, fn func(*Job)) {
+	q.mu.Lock()
+	fn(job)
+	snapshot := *job
+	q.mu.Unlock()
+
+	q.notify(snapshot)
+}
+
+func (q *Queue) notify(job Job) {
+	if q.OnUpdate != nil {
+		q.OnUpdate(job)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for ch := range q.subscribers {
+		select {
+		case ch <- job:
+		default:
+		}
+	}
+}
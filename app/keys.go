@@ -0,0 +1,213 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// KeyMap is every key binding of the app. Some keys are only used on one screen, so the
+// same key can be bound to actions of different screens, like c for clip, cue and check.
+type KeyMap struct {
+	Up       key.Binding
+	Down     key.Binding
+	PrevPage key.Binding
+	NextPage key.Binding
+	Select   key.Binding
+	Back     key.Binding
+	Quit     key.Binding
+	Help     key.Binding
+
+	Clip      key.Binding
+	ClearClip key.Binding
+	Mark      key.Binding
+	MarkAll   key.Binding
+	Cue       key.Binding
+	SyncAll   key.Binding
+	Check     key.Binding
+	Save      key.Binding
+	Open      key.Binding
+	Reveal    key.Binding
+	Copy      key.Binding
+	Delete    key.Binding
+	Stop      key.Binding
+	Sponsors  key.Binding
+}
+
+var (
+	keys     = keyMapPreset("vim")
+	helpView = help.New()
+)
+
+// KeyMapPresets are the key layouts that can be picked with "keymap" in the config
+var KeyMapPresets = []string{"arrows", "emacs", "vim"}
+
+func keyMapPreset(name string) KeyMap {
+	km := KeyMap{
+		Up:       key.NewBinding(key.WithKeys("k", "up"), key.WithHelp("", "up")),
+		Down:     key.NewBinding(key.WithKeys("j", "down"), key.WithHelp("", "down")),
+		PrevPage: key.NewBinding(key.WithKeys("h", "left"), key.WithHelp("", "prev page")),
+		NextPage: key.NewBinding(key.WithKeys("l", "right"), key.WithHelp("", "next page")),
+		Select:   key.NewBinding(key.WithKeys("enter"), key.WithHelp("", "choose")),
+		Back:     key.NewBinding(key.WithKeys("backspace"), key.WithHelp("", "back")),
+		Quit:     key.NewBinding(key.WithKeys("q", "esc", "ctrl+c"), key.WithHelp("", "quit")),
+		Help:     key.NewBinding(key.WithKeys("?"), key.WithHelp("", "help")),
+
+		Clip:      key.NewBinding(key.WithKeys("c"), key.WithHelp("", "set clip range")),
+		ClearClip: key.NewBinding(key.WithKeys("x"), key.WithHelp("", "clear clip range")),
+		Mark:      key.NewBinding(key.WithKeys(" "), key.WithHelp("", "mark")),
+		MarkAll:   key.NewBinding(key.WithKeys("a"), key.WithHelp("", "mark all")),
+		Cue:       key.NewBinding(key.WithKeys("c"), key.WithHelp("", "toggle cue sheet")),
+		SyncAll:   key.NewBinding(key.WithKeys("a"), key.WithHelp("", "sync all")),
+		Check:     key.NewBinding(key.WithKeys("c"), key.WithHelp("", "check for new")),
+		Save:      key.NewBinding(key.WithKeys("s"), key.WithHelp("", "save")),
+		Open:      key.NewBinding(key.WithKeys("o"), key.WithHelp("", "open")),
+		Reveal:    key.NewBinding(key.WithKeys("r"), key.WithHelp("", "show in folder")),
+		Copy:      key.NewBinding(key.WithKeys("y"), key.WithHelp("", "copy path")),
+		Delete:    key.NewBinding(key.WithKeys("d"), key.WithHelp("", "delete")),
+		Stop:      key.NewBinding(key.WithKeys("s"), key.WithHelp("", "stop recording")),
+		Sponsors:  key.NewBinding(key.WithKeys("b"), key.WithHelp("", "sponsorblock")),
+	}
+
+	switch name {
+	case "arrows":
+		km.Up.SetKeys("up")
+		km.Down.SetKeys("down")
+		km.PrevPage.SetKeys("left", "pgup")
+		km.NextPage.SetKeys("right", "pgdown")
+	case "emacs":
+		km.Up.SetKeys("ctrl+p", "up")
+		km.Down.SetKeys("ctrl+n", "down")
+		km.PrevPage.SetKeys("alt+v", "pgup")
+		km.NextPage.SetKeys("ctrl+v", "pgdown")
+		km.Quit.SetKeys("ctrl+g", "esc", "ctrl+c")
+	}
+	return km
+}
+
+// bindings names the bindings for the "keys" overrides in the config
+func (km *KeyMap) bindings() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"up":         &km.Up,
+		"down":       &km.Down,
+		"prev_page":  &km.PrevPage,
+		"next_page":  &km.NextPage,
+		"select":     &km.Select,
+		"back":       &km.Back,
+		"quit":       &km.Quit,
+		"help":       &km.Help,
+		"clip":       &km.Clip,
+		"clear_clip": &km.ClearClip,
+		"mark":       &km.Mark,
+		"mark_all":   &km.MarkAll,
+		"cue":        &km.Cue,
+		"sync_all":   &km.SyncAll,
+		"check":      &km.Check,
+		"save":       &km.Save,
+		"open":       &km.Open,
+		"reveal":     &km.Reveal,
+		"copy":       &km.Copy,
+		"delete":     &km.Delete,
+		"stop":       &km.Stop,
+		"sponsors":   &km.Sponsors,
+	}
+}
+
+// UseKeyMap starts from a preset and rebinds the actions in overrides, an action bound to no
+// keys is turned off. The help labels are generated from the keys that end up bound.
+func UseKeyMap(preset string, overrides map[string][]string) error {
+	if preset == "" {
+		preset = "vim"
+	}
+	known := false
+	for _, name := range KeyMapPresets {
+		known = known || name == preset
+	}
+	if !known {
+		return fmt.Errorf("keymap must be one of %s, not %q", strings.Join(KeyMapPresets, ", "), preset)
+	}
+
+	km := keyMapPreset(preset)
+	bindings := km.bindings()
+	for action, boundKeys := range overrides {
+		b, ok := bindings[action]
+		if !ok {
+			var actions []string
+			for name := range bindings {
+				actions = append(actions, name)
+			}
+			sort.Strings(actions)
+			return fmt.Errorf("unknown key action %q, available: %s", action, strings.Join(actions, ", "))
+		}
+		if len(boundKeys) == 0 {
+			b.SetEnabled(false)
+			continue
+		}
+		b.SetKeys(boundKeys...)
+	}
+	for _, b := range bindings {
+		b.SetHelp(keyLabel(b.Keys()), b.Help().Desc)
+	}
+
+	keys = km
+	return nil
+}
+
+func init() {
+	UseKeyMap("vim", nil)
+}
+
+// keyLabel is how keys are shown in the help, e.g. "k/↑". Only the first two keys are
+// shown, the others are usually fallbacks like ctrl+c.
+func keyLabel(boundKeys []string) string {
+	if len(boundKeys) > 2 {
+		boundKeys = boundKeys[:2]
+	}
+	arrows := map[string]string{"up": "↑", "down": "↓", "left": "←", "right": "→", " ": "space"}
+	labels := make([]string, len(boundKeys))
+	for i, k := range boundKeys {
+		if arrow, ok := arrows[k]; ok {
+			k = arrow
+		}
+		labels[i] = k
+	}
+	return strings.Join(labels, "/")
+}
+
+// withDesc is b described for one screen, e.g. select as "download"
+func withDesc(b key.Binding, desc string) key.Binding {
+	b.SetHelp(b.Help().Key, desc)
+	return b
+}
+
+// typedKey reports whether a key press is text, which a screen that is typing keeps for its input
+func typedKey(msg tea.KeyMsg) bool {
+	return msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace
+}
+
+// listKeys are the keys of a paged list, the page keys only once there is more than one page
+func listKeys(total, perPage int) []key.Binding {
+	bindings := []key.Binding{keys.Up, keys.Down}
+	if total > perPage {
+		bindings = append(bindings, keys.PrevPage, keys.NextPage)
+	}
+	return bindings
+}
+
+// keyedScreen is implemented by screens with keys of their own, they show up in the help
+// footer and the help overlay for as long as the screen is on top
+type keyedScreen interface {
+	Keys() []key.Binding
+}
+
+func shortHelp(bindings []key.Binding) string {
+	return helpView.ShortHelpView(bindings)
+}
+
+func fullHelp(groups ...[]key.Binding) string {
+	return helpView.FullHelpView(groups)
+}
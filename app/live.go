@@ -0,0 +1,368 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/config"
+	"github.com/AbdelilahOu/Bubly-cli-app/utils"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// liveRetryInterval is how often yt-dlp checks whether a stream that hasn't started has
+	liveRetryInterval = "15"
+	// liveStopTimeout is how long yt-dlp gets to finish the file after being stopped
+	liveStopTimeout = 30 * time.Second
+	// recordingPrefix marks the line yt-dlp prints the recording's file name on before it starts
+	recordingPrefix = "bubly-file:"
+)
+
+// doRecordLive records a live stream until it ends or the job is stopped. The file is
+// written as MPEG-TS without a .part file, so it plays even when yt-dlp has to be killed.
+func doRecordLive(job *Job) error {
+	os.MkdirAll(outputPath(), 0755)
+
+	var path, ffmpegPath string
+	if isWindows() {
+		path = "bin/yt-dlp.exe"
+		ffmpegPath = "bin/ffmpeg.exe"
+	} else {
+		path = "bin/yt-dlp"
+		ffmpegPath = "bin/ffmpeg"
+	}
+
+	logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("Error creating log file: %v", err)
+	}
+	defer logFile.Close()
+
+	var outBuf, errBuf strings.Builder
+	// deferred so that downloads failing halfway through still have their title
+	defer func() { job.reportTitle(printedTitle(outBuf.String())) }()
+
+	_, err = os.Stat(ffmpegPath)
+	useFfmpeg := err == nil
+
+	var args []string
+	site := DetectSite(job.URL).Name
+	format := job.Format
+	if job.Kind == JobAudio {
+		if format == "" {
+			format = config.Current().AudioFormatFor(site)
+		}
+		args = append(args, "-f", format, "-x", "--audio-quality", "0")
+	} else {
+		if format == "" {
+			format = config.Current().VideoFormatFor(site)
+		}
+		args = append(args, "-f", format)
+	}
+
+	if useFfmpeg {
+		args = append(args, "--ffmpeg-location", ffmpegPath)
+	} else {
+		fmt.Fprintf(logFile, "Warning: ffmpeg not found. Live streams are usually recorded with ffmpeg.\n")
+	}
+	if job.Live == "start" {
+		args = append(args, "--live-from-start")
+	}
+
+	output := job.OutputName(outputPath(defaultOutputName))
+	args = append(args, rateLimitArgs(job.rate)...)
+	args = append(args, "--wait-for-video", liveRetryInterval, "--hls-use-mpegts", "--no-part", "--newline")
+	args = append(args, titleArgs...)
+	args = append(args, "--print", "before_dl:"+recordingPrefix+"%(filename)s")
+	args = append(args, "--print", "after_move:filepath", "--no-quiet")
+	args = append(args, "-o", output+".%(ext)s", job.URL)
+
+	name := &recordingName{}
+	cmd := job.command(path, args...)
+	cmd.Stdout = io.MultiWriter(&outBuf, logFile, name)
+	cmd.Stderr = io.MultiWriter(&errBuf, logFile)
+	if err := cmd.Start(); err != nil {
+		return ytdlpError("recording", err, "")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-job.stopping:
+				// like ctrl+c, yt-dlp tells ffmpeg to finish the file and then exits
+				interruptProcess(cmd.Process)
+				select {
+				case <-done:
+				case <-time.After(liveStopTimeout):
+					cmd.Process.Kill()
+				}
+				return
+			case <-ticker.C:
+				job.reportRecorded(recordedSize(name.String()))
+			}
+		}
+	}()
+	err = cmd.Wait()
+	close(done)
+
+	files := recordedFiles(name.String())
+	if file := lastLine(outBuf.String()); file != "" {
+		files = []string{file}
+	}
+	if err != nil && !(job.Stopping() && len(files) > 0) {
+		return ytdlpError("recording", err, errBuf.String())
+	}
+	job.reportRecorded(recordedSize(name.String()))
+	job.reportFiles(files)
+	return nil
+}
+
+// interruptProcess asks a process to stop, Windows has no interrupt to send so it's killed
+func interruptProcess(p *os.Process) {
+	if isWindows() {
+		p.Kill()
+		return
+	}
+	p.Signal(os.Interrupt)
+}
+
+// recordingName is the file name yt-dlp printed before recording, without the extension.
+// The output template is only filled in by yt-dlp, the files are found from this name.
+type recordingName struct {
+	mu   sync.Mutex
+	line strings.Builder
+	name string
+}
+
+func (w *recordingName) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, b := range p {
+		if b != '\n' {
+			w.line.WriteByte(b)
+			continue
+		}
+		if file, ok := strings.CutPrefix(strings.TrimSpace(w.line.String()), recordingPrefix); ok {
+			w.name = strings.TrimSuffix(file, filepath.Ext(file))
+		}
+		w.line.Reset()
+	}
+	return len(p), nil
+}
+
+func (w *recordingName) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.name
+}
+
+// recordingMatches are the files named name with any extension, none until yt-dlp printed the name
+func recordingMatches(name string) []string {
+	if name == "" {
+		return nil
+	}
+	// read rather than globbed, titles and the [id] of the name aren't patterns
+	entries, _ := os.ReadDir(filepath.Dir(name))
+	var matches []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), filepath.Base(name)+".") {
+			matches = append(matches, filepath.Join(filepath.Dir(name), entry.Name()))
+		}
+	}
+	return matches
+}
+
+// recordedSize adds up the files of a recording, separate streams and fragments included
+func recordedSize(name string) int64 {
+	matches := recordingMatches(name)
+	var size int64
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// recordedFiles are the playable files of a recording, for when yt-dlp didn't get to say
+func recordedFiles(name string) []string {
+	matches := recordingMatches(name)
+	var files []string
+	for _, match := range matches {
+		if isMedia(match) {
+			files = append(files, match)
+		}
+	}
+	return files
+}
+
+type recordTickMsg struct {
+	screen *recordScreen
+}
+
+// recordScreen starts and follows the recording of a live stream or a premiere. It ticks
+// every second for the countdown and the recorded time and size.
+type recordScreen struct {
+	layout  *Layout
+	url     string
+	info    *VideoInfo
+	kind    JobKind
+	options []ViewsOptions
+	choice  int
+	job     *Job
+	err     string
+}
+
+func newRecordScreen(layout *Layout, url string, info *VideoInfo, kind JobKind) *recordScreen {
+	options := []ViewsOptions{
+		{View: "now", ChoiceLabel: "Record from now"},
+		{View: "start", ChoiceLabel: "Record from the start"},
+	}
+	if info.LiveStatus == "is_upcoming" {
+		options = []ViewsOptions{{View: "start", ChoiceLabel: "Wait for it to start and record it"}}
+	}
+	return &recordScreen{layout: layout, url: url, info: info, kind: kind, options: options}
+}
+
+func (s *recordScreen) Init() tea.Cmd {
+	return s.tick()
+}
+
+func (s *recordScreen) tick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return recordTickMsg{screen: s}
+	})
+}
+
+func (s *recordScreen) Title() string {
+	return "Record"
+}
+
+func (s *recordScreen) Keys() []key.Binding {
+	switch {
+	case s.job == nil:
+		return []key.Binding{keys.Up, keys.Down, withDesc(keys.Select, "record")}
+	case s.job.Status == JobRunning && !s.job.Stopping():
+		return []key.Binding{keys.Stop}
+	}
+	return nil
+}
+
+func (s *recordScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
+	switch msg := msg.(type) {
+	case recordTickMsg:
+		if msg.screen != s {
+			return s, nil
+		}
+		if s.job == nil {
+			return s, s.tick()
+		}
+		job, _ := SharedQueue().Get(s.job.ID)
+		s.job = &job
+		switch job.Status {
+		case JobDone:
+			return s, showFiles(s.layout, "Recording saved", job.Files, 0)
+		case JobFailed:
+			s.err = job.Error
+			return s, nil
+		case JobCancelled:
+			s.err = "The recording was cancelled"
+			return s, nil
+		}
+		return s, s.tick()
+	case tea.KeyMsg:
+		if s.job != nil {
+			if key.Matches(msg, keys.Stop) && s.job.Status == JobRunning {
+				SharedQueue().Stop(s.job.ID)
+			}
+			return s, nil
+		}
+		switch {
+		case key.Matches(msg, keys.Down):
+			if s.choice < len(s.options)-1 {
+				s.choice++
+			}
+		case key.Matches(msg, keys.Up):
+			if s.choice > 0 {
+				s.choice--
+			}
+		case key.Matches(msg, keys.Select):
+			job := &Job{Kind: s.kind, URL: s.url, Live: s.options[s.choice].View}
+			warning, err := SharedQueue().CheckSpace(job)
+			if err != nil {
+				return s, warn(err.Error())
+			}
+			queued := SharedQueue().Add(job)
+			s.job = &queued
+			return s, warn(warning)
+		}
+	}
+	return s, nil
+}
+
+// startsIn is the time left until a premiere, 0 once it's due or when it's unknown
+func (s *recordScreen) startsIn() time.Duration {
+	if s.info.LiveStatus != "is_upcoming" || s.info.ReleaseTimestamp == 0 {
+		return 0
+	}
+	left := time.Until(time.Unix(s.info.ReleaseTimestamp, 0))
+	if left < 0 {
+		return 0
+	}
+	return left.Round(time.Second)
+}
+
+func (s *recordScreen) View() string {
+	var b strings.Builder
+	b.WriteString(infoHeader(s.layout, s.info))
+
+	if s.err != "" {
+		b.WriteString(ErrorStyle(s.layout.Wrap("Error: " + s.err)))
+		return b.String()
+	}
+
+	countdown := ""
+	if left := s.startsIn(); left > 0 {
+		countdown = "Starts in " + utils.FormatDuration(left.Seconds())
+	}
+
+	if s.job == nil {
+		if countdown != "" {
+			b.WriteString("⏰ " + countdown + "\n\n")
+		}
+		b.WriteString(fmt.Sprintf(
+			strings.Repeat("%s\n", len(s.options)),
+			destructureOptions(s.options, s.choice)...,
+		))
+		return b.String()
+	}
+
+	job := s.job
+	switch {
+	case job.Status == JobQueued:
+		b.WriteString("Waiting for a download slot " + spinnerFrame())
+	case job.Stopping():
+		b.WriteString("⏹ Stopping, finishing the file " + spinnerFrame() + "\n")
+		b.WriteString(utils.FormatSize(job.Recorded) + " recorded")
+	case job.RecordingSince.IsZero() && countdown != "":
+		b.WriteString("⏰ " + countdown + ", recording starts then " + spinnerFrame())
+	case job.RecordingSince.IsZero():
+		b.WriteString("Waiting for the stream " + spinnerFrame())
+	default:
+		elapsed := time.Since(job.RecordingSince).Seconds()
+		b.WriteString("🔴 Recording " + utils.FormatDuration(elapsed) + " · " + utils.FormatSize(job.Recorded) + "\n\n")
+		b.WriteString(s.layout.Wrap("Stop (" + keys.Stop.Help().Key + ") whenever you like, what was recorded is kept as a playable file"))
+	}
+	return b.String()
+}
@@ -0,0 +1,209 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/utils"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type Chapter struct {
+	Title     string  `json:"title"`
+	StartTime float64 `json:"start_time"`
+	EndTime   float64 `json:"end_time"`
+}
+
+type Thumbnail struct {
+	URL        string `json:"url"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	Preference int    `json:"preference"`
+}
+
+type VideoInfo struct {
+	ID                string                     `json:"id"`
+	Title             string                     `json:"title"`
+	Channel           string                     `json:"channel"`
+	Uploader          string                     `json:"uploader"`
+	Duration          float64                    `json:"duration"`
+	UploadDate        string                     `json:"upload_date"`
+	ViewCount         int64                      `json:"view_count"`
+	LiveStatus        string                     `json:"live_status"`
+	ReleaseTimestamp  int64                      `json:"release_timestamp"`
+	WebpageURL        string                     `json:"webpage_url"`
+	Chapters          []Chapter                  `json:"chapters"`
+	Thumbnails        []Thumbnail                `json:"thumbnails"`
+	Subtitles         map[string]json.RawMessage `json:"subtitles"`
+	AutomaticCaptions map[string]json.RawMessage `json:"automatic_captions"`
+}
+
+// the -J call is the slowest step of every flow, so results are kept for the session. Live
+// streams and premieres aren't, their status changes while the app runs.
+var videoInfoCache = struct {
+	sync.Mutex
+	entries map[string]*VideoInfo
+}{entries: map[string]*VideoInfo{}}
+
+func (v *VideoInfo) Author() string {
+	if v.Channel != "" {
+		return v.Channel
+	}
+	return v.Uploader
+}
+
+func (v *VideoInfo) SubtitleCount() int {
+	count := 0
+	for lang := range v.Subtitles {
+		if lang != "live_chat" {
+			count++
+		}
+	}
+	return count
+}
+
+// IsLive is true for streams that are live or still to come, which get recorded
+func (v *VideoInfo) IsLive() bool {
+	return v.LiveStatus == "is_live" || v.LiveStatus == "is_upcoming"
+}
+
+func (v *VideoInfo) StatusLabel() string {
+	switch v.LiveStatus {
+	case "is_live":
+		return "🔴 Live now"
+	case "is_upcoming":
+		if v.ReleaseTimestamp > 0 {
+			return "⏰ Upcoming, starts " + time.Unix(v.ReleaseTimestamp, 0).Format("2006-01-02 15:04")
+		}
+		return "⏰ Upcoming"
+	case "was_live":
+		return "Recorded live stream"
+	case "post_live":
+		return "Live stream, still processing"
+	default:
+		return "Video"
+	}
+}
+
+func fetchVideoInfo(url string) tea.Cmd {
+	return func() tea.Msg {
+		info, err := backend.VideoInfo(url)
+		if err != nil {
+			return VideoInfoMsg{URL: url, Error: err.Error()}
+		}
+		return VideoInfoMsg{URL: url, Info: info}
+	}
+}
+
+func FetchVideoInfo(url string) (*VideoInfo, error) {
+	videoInfoCache.Lock()
+	cached, ok := videoInfoCache.entries[url]
+	videoInfoCache.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	var path string
+	if isWindows() {
+		path = "bin/yt-dlp.exe"
+	} else {
+		path = "bin/yt-dlp"
+	}
+
+	logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating log file: %v", err)
+	}
+	defer logFile.Close()
+
+	var outBuf, errBuf strings.Builder
+
+	// premieres and streams that haven't started have no formats yet, but their info is wanted
+	cmd := ytdlpCommand(path, "-J", "--no-playlist", "--ignore-no-formats-error", url)
+	cmd.Stdout = &outBuf
+	cmd.Stderr = io.MultiWriter(&errBuf, logFile)
+
+	err = cmd.Run()
+	if err != nil {
+		return nil, ytdlpError("fetching video info", err, errBuf.String())
+	}
+
+	var info VideoInfo
+	if err := json.Unmarshal([]byte(outBuf.String()), &info); err != nil {
+		return nil, fmt.Errorf("Error reading video info: %v", err)
+	}
+
+	for i := range info.Chapters {
+		if info.Chapters[i].Title == "" {
+			info.Chapters[i].Title = fmt.Sprintf("Chapter %d", i+1)
+		}
+	}
+
+	if !info.IsLive() {
+		videoInfoCache.Lock()
+		videoInfoCache.entries[url] = &info
+		videoInfoCache.Unlock()
+	}
+
+	return &info, nil
+}
+
+type VideoInfoMsg struct {
+	URL   string
+	Info  *VideoInfo
+	Error string
+}
+
+// VideoInfoPanel boxes up the video's details, lines longer than width are cut when it's set
+func VideoInfoPanel(info *VideoInfo, width int) string {
+	var s strings.Builder
+
+	s.WriteString(infoTitleStyle(info.Title) + "\n")
+	site := siteOf("", info)
+	byline := infoLabelStyle("Site:") + " " + site.Label
+	if author := info.Author(); author != "" {
+		byline += "   " + infoLabelStyle("Channel:") + " " + author
+	}
+	s.WriteString(byline + "\n")
+
+	var details []string
+	if info.Duration > 0 {
+		details = append(details, infoLabelStyle("Duration:")+" "+utils.FormatDuration(info.Duration))
+	}
+	if info.UploadDate != "" {
+		details = append(details, infoLabelStyle("Uploaded:")+" "+utils.FormatUploadDate(info.UploadDate))
+	}
+	if info.ViewCount > 0 {
+		details = append(details, infoLabelStyle("Views:")+" "+utils.FormatCount(info.ViewCount))
+	}
+	if len(details) > 0 {
+		s.WriteString(strings.Join(details, "   ") + "\n")
+	}
+
+	s.WriteString(infoLabelStyle("Status:") + " " + info.StatusLabel())
+
+	// most other sites have neither, the line only says so when it's news
+	if site.Name != "youtube" && len(info.Chapters) == 0 && info.SubtitleCount() == 0 && len(info.AutomaticCaptions) == 0 {
+		return infoPanel(s.String(), width)
+	}
+	subtitles := fmt.Sprintf("%d", info.SubtitleCount())
+	if len(info.AutomaticCaptions) > 0 {
+		subtitles += fmt.Sprintf(" (+%d automatic)", len(info.AutomaticCaptions))
+	}
+	s.WriteString("\n" + infoLabelStyle("Chapters:") + fmt.Sprintf(" %d", len(info.Chapters)) + "   " + infoLabelStyle("Subtitles:") + " " + subtitles)
+	return infoPanel(s.String(), width)
+}
+
+func infoPanel(content string, width int) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		// the border and padding take two cells on each side
+		lines[i] = truncateWidth(line, width-4)
+	}
+	return infoPanelStyle(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,238 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/config"
+	"github.com/AbdelilahOu/Bubly-cli-app/utils"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// notifier tells about finished downloads once the queue has nothing left to run, so a batch
+// of downloads is one notification rather than one per download. The settings are read at
+// every notification, so changing them in the settings screen applies right away.
+type notifier struct {
+	out io.Writer
+	// terminal is false when out is a file or a pipe, which never get escapes
+	terminal bool
+	notified map[int]bool
+	progress int
+}
+
+// WatchQueue sends the notifications of the settings for the downloads of q, the terminal
+// ones are written to out. The returned function stops watching and clears the progress.
+func WatchQueue(q *Queue, out io.Writer) func() {
+	return watchQueue(q, out, utils.IsTerminal(out))
+}
+
+// WatchProgramQueue is WatchQueue for the app, which draws p to out: the escapes are printed
+// through p so they don't land in the middle of a frame. Stop it once p has quit.
+func WatchProgramQueue(q *Queue, p *tea.Program, out io.Writer) func() {
+	w := &programWriter{p: p, out: out}
+	stop := watchQueue(q, w, utils.IsTerminal(out))
+	return func() {
+		w.mu.Lock()
+		w.quit = true
+		w.mu.Unlock()
+		stop()
+	}
+}
+
+func watchQueue(q *Queue, out io.Writer, terminal bool) func() {
+	n := &notifier{out: out, terminal: terminal, notified: map[int]bool{}, progress: -1}
+	updates, unsubscribe := q.Subscribe()
+	done := make(chan struct{})
+	go func() {
+		for job := range updates {
+			n.update(q, job)
+		}
+		close(done)
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			unsubscribe()
+			<-done
+			n.showProgress(-1, false)
+		})
+	}
+}
+
+// programWriter prints above the view of a running program, and to out once it has quit
+type programWriter struct {
+	p    *tea.Program
+	out  io.Writer
+	mu   sync.Mutex
+	quit bool
+}
+
+func (w *programWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.quit {
+		return w.out.Write(b)
+	}
+	// printed lines go above the view, the cursor goes back up so the escape's line is
+	// painted over rather than pushing the view down a line every time
+	w.p.Send(tea.Println(string(b) + "\x1b[A")())
+	return len(b), nil
+}
+
+func (n *notifier) update(q *Queue, job Job) {
+	if job.Status == JobQueued {
+		return
+	}
+
+	// the jobs since the last notification, finished ones count as complete
+	var round []Job
+	var total float64
+	running, failed := false, false
+	for _, j := range q.Jobs() {
+		if n.notified[j.ID] {
+			continue
+		}
+		round = append(round, j)
+		if j.IsFinished() {
+			total += 100
+		} else {
+			total += j.Progress
+			running = true
+		}
+		failed = failed || j.Status == JobFailed
+	}
+	if len(round) == 0 {
+		return
+	}
+	if running {
+		n.showProgress(int(total/float64(len(round))), failed)
+		return
+	}
+
+	for _, j := range round {
+		n.notified[j.ID] = true
+	}
+	n.showProgress(-1, false)
+	n.notify(round)
+}
+
+// notify sends one notification about jobs, nothing when they were all cancelled
+func (n *notifier) notify(jobs []Job) {
+	var done, failed []Job
+	for _, job := range jobs {
+		switch job.Status {
+		case JobDone:
+			done = append(done, job)
+		case JobFailed:
+			failed = append(failed, job)
+		}
+	}
+
+	var title, body string
+	switch {
+	case len(done)+len(failed) == 0:
+		return
+	case len(done) == 1 && len(failed) == 0:
+		title, body = "Download finished", fmt.Sprintf("%s %s", done[0].Kind, done[0].URL)
+	case len(failed) == 1 && len(done) == 0:
+		title, body = "Download failed", failed[0].Error
+	case len(failed) == 0:
+		title, body = "Downloads finished", fmt.Sprintf("%d downloads done", len(done))
+	default:
+		title, body = "Some downloads failed", fmt.Sprintf("%d done, %d failed", len(done), len(failed))
+	}
+
+	settings := config.Current().Notifications
+	if n.terminal && settings.Bell {
+		io.WriteString(n.out, "\a")
+	}
+	if n.terminal {
+		switch settings.Terminal {
+		case "9":
+			n.escape("\x1b]9;" + escapeText(title+": "+body) + "\a")
+		case "777":
+			n.escape("\x1b]777;notify;" + escapeText(title) + ";" + escapeText(body) + "\a")
+		}
+	}
+	if settings.Desktop {
+		sendDesktopNotification(title, body)
+	}
+}
+
+// showProgress sets the taskbar progress with ConEmu's OSC 9;4, which Windows Terminal,
+// WezTerm and Ghostty also understand. A negative percent removes it.
+func (n *notifier) showProgress(percent int, failed bool) {
+	if !n.terminal {
+		return
+	}
+	if !config.Current().Notifications.Progress {
+		percent = -1
+	}
+	if percent < 0 {
+		if n.progress >= 0 {
+			n.escape("\x1b]9;4;0\a")
+		}
+		n.progress = -1
+		return
+	}
+	if percent == n.progress {
+		return
+	}
+	n.progress = percent
+	state := 1
+	if failed {
+		state = 2
+	}
+	n.escape(fmt.Sprintf("\x1b]9;4;%d;%d\a", state, percent))
+}
+
+// escape writes an escape sequence, wrapped for tmux which otherwise keeps it to itself
+func (n *notifier) escape(seq string) {
+	if os.Getenv("TMUX") != "" {
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	io.WriteString(n.out, seq)
+}
+
+// escapeText drops what would end an escape sequence early, like control characters and
+// the ; between the fields of OSC 777
+func escapeText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == ';' {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// sendDesktopNotification calls the freedesktop notification service over D-Bus through
+// gdbus, or notify-send when gdbus is missing. Failing is fine, there's always the terminal.
+func sendDesktopNotification(title, body string) {
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd", "netbsd", "dragonfly":
+	default:
+		return
+	}
+
+	err := exec.Command("gdbus", "call", "--session",
+		"--dest", "org.freedesktop.Notifications",
+		"--object-path", "/org/freedesktop/Notifications",
+		"--method", "org.freedesktop.Notifications.Notify",
+		gvariantString("Bubly"), "0", gvariantString(""), gvariantString(title), gvariantString(body),
+		"[]", "{}", "5000").Run()
+	if err != nil {
+		exec.Command("notify-send", "--app-name=Bubly", title, body).Run()
+	}
+}
+
+// gvariantString quotes s in the GVariant text format gdbus parses its arguments with
+func gvariantString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
@@ -0,0 +1,180 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/config"
+	"github.com/AbdelilahOu/Bubly-cli-app/utils"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type queueTickMsg struct {
+	screen *queueScreen
+}
+
+// queueScreen lists the jobs of the shared queue, newest first, and ticks every second to
+// follow them. Queued jobs waiting for the schedule say when they start.
+type queueScreen struct {
+	layout *Layout
+	jobs   []Job
+	choice int
+}
+
+func newQueueScreen(layout *Layout) *queueScreen {
+	s := &queueScreen{layout: layout}
+	s.refresh()
+	return s
+}
+
+func (s *queueScreen) Init() tea.Cmd {
+	return s.tick()
+}
+
+func (s *queueScreen) tick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return queueTickMsg{screen: s}
+	})
+}
+
+func (s *queueScreen) refresh() {
+	jobs := SharedQueue().Jobs()
+	s.jobs = make([]Job, len(jobs))
+	for i, job := range jobs {
+		s.jobs[len(jobs)-1-i] = job
+	}
+	if s.choice >= len(s.jobs) {
+		s.choice = max(len(s.jobs)-1, 0)
+	}
+}
+
+func (s *queueScreen) Title() string {
+	return "Download queue 📋"
+}
+
+func (s *queueScreen) Keys() []key.Binding {
+	if len(s.jobs) == 0 {
+		return nil
+	}
+	bindings := listKeys(len(s.jobs), s.layout.ItemsPerPage)
+	job := s.jobs[s.choice]
+	switch {
+	case job.Status == JobDone && len(job.Files) > 0:
+		bindings = append(bindings, withDesc(keys.Select, "files"))
+	case job.Live != "" && job.Status == JobRunning && !job.Stopping():
+		bindings = append(bindings, keys.Stop, withDesc(keys.Delete, "cancel"))
+	case !job.IsFinished():
+		bindings = append(bindings, withDesc(keys.Delete, "cancel"))
+	}
+	return bindings
+}
+
+func (s *queueScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
+	switch msg := msg.(type) {
+	case queueTickMsg:
+		if msg.screen != s {
+			return s, nil
+		}
+		s.refresh()
+		return s, s.tick()
+	case tea.KeyMsg:
+		if len(s.jobs) == 0 {
+			return s, nil
+		}
+		if movePage(msg, &s.choice, len(s.jobs), s.layout.ItemsPerPage) {
+			return s, nil
+		}
+		job := s.jobs[s.choice]
+		switch {
+		case key.Matches(msg, keys.Select) && job.Status == JobDone && len(job.Files) > 0:
+			return s, showFiles(s.layout, "Job "+fmt.Sprint(job.ID), job.Files, 0)
+		case key.Matches(msg, keys.Stop) && job.Live != "":
+			if _, err := SharedQueue().Stop(job.ID); err != nil {
+				return s, warn(err.Error())
+			}
+		case key.Matches(msg, keys.Delete) && !job.IsFinished():
+			if _, err := SharedQueue().Cancel(job.ID); err != nil {
+				return s, warn(err.Error())
+			}
+		default:
+			return s, nil
+		}
+		s.refresh()
+	}
+	return s, nil
+}
+
+// queueStatus is the state of a job in a few words
+func queueStatus(job Job) string {
+	switch {
+	case job.Status == JobQueued && !job.ScheduledFor.IsZero():
+		return "scheduled for " + scheduleLabel(job.ScheduledFor)
+	case job.Status == JobRunning && job.Stopping():
+		return "stopping " + spinnerFrame()
+	case job.Status == JobRunning && job.Live != "":
+		if job.RecordingSince.IsZero() {
+			return "waiting for the stream " + spinnerFrame()
+		}
+		return "recording " + utils.FormatSize(job.Recorded)
+	case job.Status == JobRunning:
+		return fmt.Sprintf("%.0f%% %s", job.Progress, spinnerFrame())
+	case job.Status == JobDone:
+		return "done in " + job.Elapsed().Round(time.Second).String()
+	}
+	return string(job.Status)
+}
+
+func (s *queueScreen) View() string {
+	var b strings.Builder
+
+	var notes []string
+	if limit := config.Current().RateLimit; limit != "" {
+		notes = append(notes, "Speed limit: "+limit+"/s")
+	}
+	if schedule := config.Current().Schedule; len(schedule) > 0 {
+		notes = append(notes, "Downloads start between "+strings.Join(schedule, ", "))
+	}
+	if len(notes) > 0 {
+		b.WriteString(s.layout.Truncate(infoLabelStyle(strings.Join(notes, " · "))) + "\n\n")
+	}
+
+	if len(s.jobs) == 0 {
+		b.WriteString("Nothing downloaded yet. Downloads started from the menu show up here.")
+		return b.String()
+	}
+
+	start, end := pageBounds(s.choice, len(s.jobs), s.layout.ItemsPerPage)
+	for i := start; i < end; i++ {
+		job := s.jobs[i]
+		cursor := "  "
+		if s.choice == i {
+			cursor = "> "
+		}
+
+		status := queueStatus(job)
+		if job.Status == JobFailed {
+			status = urlErrorStyle(status)
+		} else {
+			status = videoFileSizeStyle(status)
+		}
+		name := job.URL
+		if job.Title != "" {
+			name = job.Title
+		}
+		line := fmt.Sprintf("%s%s %s %s %s",
+			cursor,
+			subtitleLangStyle(fmt.Sprintf("#%d", job.ID)),
+			videoFormatStyle(string(job.Kind)),
+			status,
+			name)
+		b.WriteString(s.layout.Truncate(line) + "\n")
+	}
+	b.WriteString(pageFooter(s.choice, len(s.jobs), s.layout.ItemsPerPage))
+
+	if job := s.jobs[s.choice]; job.Status == JobFailed {
+		b.WriteString("\n" + ErrorStyle(s.layout.Wrap(job.Error)))
+	}
+	return b.String()
+}
@@ -0,0 +1,170 @@
+package app
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/config"
+	"github.com/AbdelilahOu/Bubly-cli-app/utils"
+)
+
+// ReportEntry is one job of a run report. Format is the format the job asked yt-dlp for, the
+// language for subtitles, Bytes the size of its files and Duration the seconds it took.
+type ReportEntry struct {
+	ID         int        `json:"id"`
+	URL        string     `json:"url"`
+	Title      string     `json:"title"`
+	Kind       JobKind    `json:"kind"`
+	Format     string     `json:"format"`
+	Files      []string   `json:"files"`
+	Bytes      int64      `json:"bytes"`
+	Duration   float64    `json:"duration"`
+	Status     JobStatus  `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	ErrorClass string     `json:"error_class,omitempty"`
+	Started    *time.Time `json:"started,omitempty"`
+	Finished   *time.Time `json:"finished,omitempty"`
+}
+
+var reportColumns = []string{"id", "url", "title", "kind", "format", "files", "bytes", "duration", "status", "error", "error_class", "started", "finished"}
+
+func NewReportEntry(job Job) ReportEntry {
+	entry := ReportEntry{
+		ID:         job.ID,
+		URL:        job.URL,
+		Title:      job.Title,
+		Kind:       job.Kind,
+		Format:     reportFormat(job),
+		Files:      job.Files,
+		Bytes:      job.Recorded,
+		Duration:   job.Elapsed().Round(time.Millisecond).Seconds(),
+		Status:     job.Status,
+		ErrorClass: job.ErrorClass,
+	}
+	if !job.Started.IsZero() {
+		entry.Started = &job.Started
+	}
+	if !job.Finished.IsZero() {
+		entry.Finished = &job.Finished
+	}
+	if entry.Files == nil {
+		entry.Files = []string{}
+	}
+	if job.Status != JobDone && job.Status != JobCancelled {
+		entry.Error = job.Error
+	}
+
+	var size int64
+	for _, file := range job.Files {
+		if info, err := os.Stat(file); err == nil {
+			size += info.Size()
+		}
+	}
+	if size > 0 {
+		entry.Bytes = size
+	}
+	return entry
+}
+
+// reportFormat is the format a job downloaded, the default one when it didn't pick any
+func reportFormat(job Job) string {
+	switch {
+	case job.Kind == JobSubtitles:
+		return job.Lang
+	case job.Format != "":
+		return job.Format
+	case job.Kind == JobAudio:
+		return config.Current().AudioFormatFor(DetectSite(job.URL).Name)
+	}
+	return config.Current().VideoFormatFor(DetectSite(job.URL).Name)
+}
+
+func NewReport(jobs []Job) []ReportEntry {
+	entries := make([]ReportEntry, len(jobs))
+	for i, job := range jobs {
+		entries[i] = NewReportEntry(job)
+	}
+	return entries
+}
+
+// WriteReportJSON writes the entries as a JSON array
+func WriteReportJSON(w io.Writer, entries []ReportEntry) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// WriteReportCSV writes the entries with a header row, the files of a job joined by "; "
+func WriteReportCSV(w io.Writer, entries []ReportEntry) error {
+	out := csv.NewWriter(w)
+	out.Write(reportColumns)
+	for _, entry := range entries {
+		out.Write([]string{
+			strconv.Itoa(entry.ID),
+			entry.URL,
+			entry.Title,
+			string(entry.Kind),
+			entry.Format,
+			strings.Join(entry.Files, "; "),
+			strconv.FormatInt(entry.Bytes, 10),
+			strconv.FormatFloat(entry.Duration, 'f', -1, 64),
+			string(entry.Status),
+			entry.Error,
+			entry.ErrorClass,
+			reportTime(entry.Started),
+			reportTime(entry.Finished),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func reportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// reportPath is path with {time} replaced by the time, so that runs don't overwrite each other
+func reportPath(path string, now time.Time) string {
+	return strings.ReplaceAll(path, "{time}", now.Format("20060102-150405"))
+}
+
+// SaveReport writes a report to path, as CSV for a .csv file and JSON otherwise, and returns
+// the path written. Nothing is written for a run without jobs.
+func SaveReport(path string, entries []ReportEntry) (string, error) {
+	if path == "" || len(entries) == 0 {
+		return "", nil
+	}
+	path = reportPath(path, time.Now())
+
+	var buf bytes.Buffer
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		err = WriteReportCSV(&buf, entries)
+	} else {
+		err = WriteReportJSON(&buf, entries)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("creating report folder: %v", err)
+		}
+	}
+	if err := utils.WriteFileAtomic(path, buf.Bytes(), 0644); err != nil {
+		return "", fmt.Errorf("writing report: %v", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,313 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muesli/reflow/ansi"
+	"github.com/muesli/reflow/truncate"
+	"github.com/muesli/reflow/wordwrap"
+	"github.com/muesli/reflow/wrap"
+)
+
+// Screen is one page of the app. Screens are pushed onto the Router's stack and keep
+// their own state, so going back shows the previous screen exactly as it was left.
+type Screen interface {
+	Init() tea.Cmd
+	Update(msg tea.Msg) (Screen, tea.Cmd)
+	View() string
+	// Title is the screen's part of the breadcrumbs in the title bar
+	Title() string
+}
+
+// typingScreen is implemented by screens with a focused text input. While Typing is true
+// q and backspace go to the input, which goes back by itself on backspace when empty.
+type typingScreen interface {
+	Typing() bool
+}
+
+// Layout is shared by every screen, so changing it applies to the whole stack
+type Layout struct {
+	ItemsPerPage int
+	// Width and Height are the terminal's size, zero until the first tea.WindowSizeMsg
+	Width  int
+	Height int
+	// Compact drops the info panel and long breadcrumbs on small terminals
+	Compact bool
+	// PageSize fixes ItemsPerPage instead of fitting it to the window, AlwaysCompact keeps
+	// the compact layout on any window. Both come from the settings.
+	PageSize      int
+	AlwaysCompact bool
+}
+
+const (
+	// layoutIndent is the indent the app view is rendered with
+	layoutIndent = 2
+	// layoutChrome is the height of everything around a list: title, info panel, headings,
+	// page footer, help and a warning. Compact mode swaps the info panel for one line.
+	layoutChrome        = 21
+	layoutCompactChrome = 15
+	minItemsPerPage     = 3
+)
+
+// Configure applies the layout settings, right away when the window size is already known
+func (l *Layout) Configure(pageSize int, alwaysCompact bool) {
+	l.PageSize = pageSize
+	l.AlwaysCompact = alwaysCompact
+	if l.PageSize > 0 {
+		l.ItemsPerPage = l.PageSize
+	}
+	l.Compact = l.AlwaysCompact
+	if l.Height > 0 {
+		l.Resize(l.Width, l.Height)
+	}
+}
+
+// Resize fits the layout to a new terminal size
+func (l *Layout) Resize(width, height int) {
+	l.Width = width
+	l.Height = height
+	l.Compact = l.AlwaysCompact || width < 70 || height < 22
+
+	if l.PageSize > 0 {
+		l.ItemsPerPage = l.PageSize
+		return
+	}
+	chrome := layoutChrome
+	if l.Compact {
+		chrome = layoutCompactChrome
+	}
+	l.ItemsPerPage = height - chrome
+	if l.ItemsPerPage < minItemsPerPage {
+		l.ItemsPerPage = minItemsPerPage
+	}
+}
+
+// ContentWidth is the width inside the app's indent, zero when the size isn't known yet
+func (l *Layout) ContentWidth() int {
+	if l.Width == 0 {
+		return 0
+	}
+	if l.Width <= layoutIndent+1 {
+		return 1
+	}
+	return l.Width - layoutIndent - 1
+}
+
+// Truncate cuts s, which may be styled, to one line of the content width
+func (l *Layout) Truncate(s string) string {
+	return truncateWidth(s, l.ContentWidth())
+}
+
+// Wrap breaks s to fit in a banner like ErrorStyle, words first and then anything longer
+// like a URL. Banners are padded by two cells on each side.
+func (l *Layout) Wrap(s string) string {
+	width := l.ContentWidth() - 4
+	if l.Width == 0 || width < 1 {
+		return s
+	}
+	return wrap.String(wordwrap.String(s, width), width)
+}
+
+func truncateWidth(s string, width int) string {
+	if width <= 0 || ansi.PrintableRuneWidth(s) <= width {
+		return s
+	}
+	return truncate.StringWithTail(s, uint(width), "…")
+}
+
+type Router struct {
+	stack []Screen
+}
+
+func NewRouter(root Screen) *Router {
+	return &Router{stack: []Screen{root}}
+}
+
+func (r *Router) Top() Screen {
+	return r.stack[len(r.stack)-1]
+}
+
+func (r *Router) Depth() int {
+	return len(r.stack)
+}
+
+func (r *Router) Push(s Screen) tea.Cmd {
+	r.stack = append(r.stack, s)
+	return s.Init()
+}
+
+// Pop goes back to the previous screen, the root screen is never popped
+func (r *Router) Pop() bool {
+	if len(r.stack) < 2 {
+		return false
+	}
+	r.stack[len(r.stack)-1] = nil
+	r.stack = r.stack[:len(r.stack)-1]
+	return true
+}
+
+// Typing reports whether the current screen is taking text input
+func (r *Router) Typing() bool {
+	if t, ok := r.Top().(typingScreen); ok {
+		return t.Typing()
+	}
+	return false
+}
+
+// Update sends key presses to the current screen only. Everything else, like the result of a
+// fetch or a download, goes to the whole stack so a screen below still gets its results.
+// Those carry the URL or job they're for, for screens to tell theirs apart.
+func (r *Router) Update(msg tea.Msg) tea.Cmd {
+	if _, ok := msg.(tea.KeyMsg); ok {
+		return r.UpdateTop(msg)
+	}
+
+	var cmds []tea.Cmd
+	for i, s := range r.stack {
+		var cmd tea.Cmd
+		r.stack[i], cmd = s.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+	return tea.Batch(cmds...)
+}
+
+// UpdateTop sends msg to the current screen only
+func (r *Router) UpdateTop(msg tea.Msg) tea.Cmd {
+	top, cmd := r.Top().Update(msg)
+	r.stack[len(r.stack)-1] = top
+	return cmd
+}
+
+// Keys are the current screen's own keys, for the help
+func (r *Router) Keys() []key.Binding {
+	if k, ok := r.Top().(keyedScreen); ok {
+		return k.Keys()
+	}
+	return nil
+}
+
+func (r *Router) Breadcrumbs() string {
+	titles := make([]string, len(r.stack))
+	for i, s := range r.stack {
+		titles[i] = s.Title()
+	}
+	return strings.Join(titles, " › ")
+}
+
+// View shows the breadcrumbs above the current screen. Breadcrumbs that don't fit, and all
+// of them in compact mode, are cut down to the current screen's title.
+func (r *Router) View(layout *Layout) string {
+	title := r.Breadcrumbs()
+	width := layout.ContentWidth() - 5
+	if layout.Compact || (layout.Width > 0 && ansi.PrintableRuneWidth(title) > width) {
+		title = truncateWidth(r.Top().Title(), width)
+	}
+	return TitleStyle(title) + "\n\n" + r.Top().View()
+}
+
+type PushScreenMsg struct {
+	Screen Screen
+}
+
+// PopScreenMsg goes back one screen. Warning is shown once back, Result is then sent to
+// the screen that is now on top, e.g. the clip range picked on the clip screen.
+type PopScreenMsg struct {
+	Warning string
+	Result  tea.Msg
+}
+
+type WarningMsg string
+
+func pushScreen(s Screen) tea.Cmd {
+	return func() tea.Msg {
+		return PushScreenMsg{Screen: s}
+	}
+}
+
+func popScreen() tea.Cmd {
+	return func() tea.Msg {
+		return PopScreenMsg{}
+	}
+}
+
+func popWithWarning(warning string) tea.Cmd {
+	return func() tea.Msg {
+		return PopScreenMsg{Warning: warning}
+	}
+}
+
+func popWithResult(result tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return PopScreenMsg{Result: result}
+	}
+}
+
+func warn(warning string) tea.Cmd {
+	return func() tea.Msg {
+		return WarningMsg(warning)
+	}
+}
+
+// movePage handles the up/down and page keys for a paged list, it reports whether the key was used
+func movePage(msg tea.KeyMsg, choice *int, total, perPage int) bool {
+	switch {
+	case key.Matches(msg, keys.Down):
+		if *choice+1 < total {
+			*choice++
+		}
+	case key.Matches(msg, keys.Up):
+		if *choice > 0 {
+			*choice--
+		}
+	case key.Matches(msg, keys.PrevPage):
+		if page := *choice / perPage; page > 0 {
+			*choice = (page - 1) * perPage
+		}
+	case key.Matches(msg, keys.NextPage):
+		totalPages := (total + perPage - 1) / perPage
+		if page := *choice / perPage; page < totalPages-1 {
+			*choice = (page + 1) * perPage
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// pageBounds returns the slice of items to show for the page the choice is on
+func pageBounds(choice, total, perPage int) (start, end int) {
+	start = choice / perPage * perPage
+	end = start + perPage
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+func pageFooter(choice, total, perPage int) string {
+	totalPages := (total + perPage - 1) / perPage
+	if totalPages <= 1 {
+		return ""
+	}
+	currentPage := choice / perPage
+
+	var s strings.Builder
+	s.WriteString("\n")
+	s.WriteString(fmt.Sprintf("Page %d of %d | ", currentPage+1, totalPages))
+	if currentPage > 0 {
+		s.WriteString("<-- Previous (" + keys.PrevPage.Help().Key + ") ")
+	}
+	if currentPage < totalPages-1 {
+		s.WriteString("Next (" + keys.NextPage.Help().Key + ") -->")
+	}
+	return s.String()
+}
+
+func spinnerFrame() string {
+	spinner := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+	return spinner[time.Now().UnixNano()/100000000%int64(len(spinner))]
+}
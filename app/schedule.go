@@ -0,0 +1,99 @@
+package app
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/config"
+)
+
+// scheduleRecheck is how often a job waiting for the schedule looks at it again, so that
+// changing it in the settings takes effect
+const scheduleRecheck = time.Minute
+
+// nextWindow is when the next window of the schedule opens, zero when one is open at now or
+// when there is no schedule
+func nextWindow(now time.Time, windows []config.Window) time.Time {
+	minute := now.Hour()*60 + now.Minute()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var next time.Time
+	for _, window := range windows {
+		if window.Contains(minute) {
+			return time.Time{}
+		}
+		opens := midnight.Add(time.Duration(window.Start) * time.Minute)
+		if !opens.After(now) {
+			opens = opens.AddDate(0, 0, 1)
+		}
+		if next.IsZero() || opens.Before(next) {
+			next = opens
+		}
+	}
+	return next
+}
+
+// waitForSchedule holds a job until a window of the schedule opens, false when it was
+// cancelled meanwhile. Recordings don't wait, a stream doesn't wait for them either.
+func (q *Queue) waitForSchedule(job *Job) bool {
+	if job.Live != "" {
+		return true
+	}
+	for {
+		opens := nextWindow(time.Now(), config.Current().Windows())
+		q.mu.Lock()
+		changed := !job.ScheduledFor.Equal(opens)
+		q.mu.Unlock()
+		if changed {
+			q.update(job, func(j *Job) {
+				j.ScheduledFor = opens
+			})
+		}
+		if opens.IsZero() {
+			return true
+		}
+
+		wait := time.Until(opens)
+		if wait > scheduleRecheck {
+			wait = scheduleRecheck
+		}
+		select {
+		case <-job.ctx.Done():
+			return false
+		case <-time.After(wait):
+		}
+	}
+}
+
+// rateLimit is the speed a job may download at, in bytes a second, 0 for no limit. The limit
+// of the config is shared by the download slots so that all of them together stay under it.
+func rateLimit(job *Job, slots int) int64 {
+	rate, _ := config.ParseRate(config.Current().RateLimit)
+	if rate > 0 && slots > 1 {
+		rate /= int64(slots)
+	}
+	if own, _ := config.ParseRate(job.RateLimit); own > 0 && (rate == 0 || own < rate) {
+		rate = own
+	}
+	return rate
+}
+
+// rateLimitArgs passes a speed on to yt-dlp
+func rateLimitArgs(rate int64) []string {
+	if rate == 0 {
+		return nil
+	}
+	return []string{"--limit-rate", strconv.FormatInt(rate, 10)}
+}
+
+// scheduleLabel is when a job is scheduled for, with the day when it isn't today
+func scheduleLabel(t time.Time) string {
+	now := time.Now()
+	if t.YearDay() == now.YearDay() && t.Year() == now.Year() {
+		return t.Format("15:04")
+	}
+	if t.Sub(now) < 6*24*time.Hour {
+		return t.Format("Mon 15:04")
+	}
+	return t.Format("Jan 2 15:04")
+}
@@ -0,0 +1,305 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/utils"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// searchLimit is the number of results a search asks for, more only make it slower
+const searchLimit = 30
+
+type SearchResult struct {
+	ID        string  `json:"id"`
+	Title     string  `json:"title"`
+	Channel   string  `json:"channel"`
+	Uploader  string  `json:"uploader"`
+	Duration  float64 `json:"duration"`
+	ViewCount int64   `json:"view_count"`
+}
+
+func (r SearchResult) URL() string {
+	return (&VideoURL{ID: r.ID}).String()
+}
+
+func (r SearchResult) Author() string {
+	if r.Channel != "" {
+		return r.Channel
+	}
+	return r.Uploader
+}
+
+// FetchSearch runs a ytsearch query, flat so that it's one request rather than one per result
+func FetchSearch(query string, limit int) ([]SearchResult, error) {
+	var path string
+	if isWindows() {
+		path = "bin/yt-dlp.exe"
+	} else {
+		path = "bin/yt-dlp"
+	}
+
+	logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating log file: %v", err)
+	}
+	defer logFile.Close()
+
+	var outBuf, errBuf strings.Builder
+	cmd := ytdlpCommand(path, "--flat-playlist", "-J", fmt.Sprintf("ytsearch%d:%s", limit, query))
+	cmd.Stdout = &outBuf
+	cmd.Stderr = io.MultiWriter(&errBuf, logFile)
+	if err := cmd.Run(); err != nil {
+		return nil, ytdlpError("searching", err, errBuf.String())
+	}
+	return parseSearchResults(outBuf.String())
+}
+
+func parseSearchResults(output string) ([]SearchResult, error) {
+	var playlist struct {
+		Entries []SearchResult `json:"entries"`
+	}
+	if err := json.Unmarshal([]byte(output), &playlist); err != nil {
+		return nil, fmt.Errorf("Error reading search results: %v", err)
+	}
+	// channels and playlists can show up between the videos
+	var results []SearchResult
+	for _, entry := range playlist.Entries {
+		if videoIDRe.MatchString(entry.ID) {
+			results = append(results, entry)
+		}
+	}
+	return results, nil
+}
+
+type SearchResultsMsg struct {
+	Query   string
+	Results []SearchResult
+	Error   string
+}
+
+func searchVideos(query string) tea.Cmd {
+	return func() tea.Msg {
+		results, err := backend.Search(query, searchLimit)
+		if err != nil {
+			return SearchResultsMsg{Query: query, Error: err.Error()}
+		}
+		return SearchResultsMsg{Query: query, Results: results}
+	}
+}
+
+// searchScreen asks for the keywords, the query stays in the input to refine it after going back
+type searchScreen struct {
+	layout *Layout
+	input  textinput.Model
+}
+
+func newSearchScreen(layout *Layout) *searchScreen {
+	si := textinput.New()
+	si.Placeholder = "Keywords..."
+	si.Prompt = "┃ "
+	si.CharLimit = 280
+	si.Width = inputWidth(layout)
+	si.Focus()
+	return &searchScreen{layout: layout, input: si}
+}
+
+func (s *searchScreen) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (s *searchScreen) Title() string {
+	return "Search YouTube 🔎"
+}
+
+func (s *searchScreen) Keys() []key.Binding {
+	return []key.Binding{withDesc(keys.Select, "search"), withDesc(keys.Back, "back when empty")}
+}
+
+func (s *searchScreen) Typing() bool {
+	return true
+}
+
+func (s *searchScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		s.input.Width = inputWidth(s.layout)
+		return s, nil
+	case tea.KeyMsg:
+		switch {
+		case typedKey(msg):
+		case key.Matches(msg, keys.Select):
+			query := strings.TrimSpace(s.input.Value())
+			if query == "" {
+				return s, nil
+			}
+			return s, pushScreen(newSearchResultsScreen(s.layout, query))
+		case key.Matches(msg, keys.Back):
+			if s.input.Value() == "" {
+				return s, popScreen()
+			}
+		}
+	}
+
+	var cmd tea.Cmd
+	s.input, cmd = s.input.Update(msg)
+	return s, cmd
+}
+
+func (s *searchScreen) View() string {
+	return "Search YouTube for:\n\n" + s.input.View()
+}
+
+type searchResultsScreen struct {
+	layout  *Layout
+	query   string
+	results []SearchResult
+	loaded  bool
+	choice  int
+}
+
+func newSearchResultsScreen(layout *Layout, query string) *searchResultsScreen {
+	return &searchResultsScreen{layout: layout, query: query}
+}
+
+func (s *searchResultsScreen) Init() tea.Cmd {
+	return searchVideos(s.query)
+}
+
+func (s *searchResultsScreen) Title() string {
+	return "Results"
+}
+
+func (s *searchResultsScreen) Keys() []key.Binding {
+	if len(s.results) == 0 {
+		return nil
+	}
+	return append(listKeys(len(s.results), s.layout.ItemsPerPage), keys.Select)
+}
+
+func (s *searchResultsScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
+	switch msg := msg.(type) {
+	case SearchResultsMsg:
+		if msg.Query != s.query || s.loaded {
+			return s, nil
+		}
+		if msg.Error != "" {
+			return s, popWithWarning(msg.Error)
+		}
+		s.results = msg.Results
+		s.loaded = true
+	case tea.KeyMsg:
+		if len(s.results) == 0 {
+			return s, nil
+		}
+		if movePage(msg, &s.choice, len(s.results), s.layout.ItemsPerPage) {
+			return s, nil
+		}
+		if key.Matches(msg, keys.Select) {
+			return s, pushScreen(newSearchActionsScreen(s.layout, s.results[s.choice]))
+		}
+	}
+	return s, nil
+}
+
+func (s *searchResultsScreen) View() string {
+	var b strings.Builder
+	if !s.loaded {
+		b.WriteString("Searching YouTube for: " + s.query + " " + spinnerFrame() + "\n")
+		return b.String()
+	}
+	if len(s.results) == 0 {
+		b.WriteString(WarningStyle(s.layout.Wrap("Nothing found for \"" + s.query + "\"")))
+		return b.String()
+	}
+
+	start, end := pageBounds(s.choice, len(s.results), s.layout.ItemsPerPage)
+	for i := start; i < end; i++ {
+		result := s.results[i]
+		cursor := "  "
+		if s.choice == i {
+			cursor = "> "
+		}
+
+		var details []string
+		if author := result.Author(); author != "" {
+			details = append(details, author)
+		}
+		if result.Duration > 0 {
+			details = append(details, utils.FormatDuration(result.Duration))
+		}
+		if result.ViewCount > 0 {
+			details = append(details, utils.FormatCount(result.ViewCount)+" views")
+		}
+		line := cursor + chapterTitleStyle(result.Title) + " " + chapterTimeStyle(strings.Join(details, " · "))
+		b.WriteString(s.layout.Truncate(line) + "\n")
+	}
+	b.WriteString(pageFooter(s.choice, len(s.results), s.layout.ItemsPerPage))
+	return b.String()
+}
+
+// searchActionsScreen picks what to do with a search result, the entries of the menu that
+// take a URL
+type searchActionsScreen struct {
+	layout  *Layout
+	result  SearchResult
+	options []ViewsOptions
+	choice  int
+}
+
+func newSearchActionsScreen(layout *Layout, result SearchResult) *searchActionsScreen {
+	var options []ViewsOptions
+	for _, option := range MenuOptions {
+		if urlTool(layout, option.View) != nil {
+			options = append(options, option)
+		}
+	}
+	return &searchActionsScreen{layout: layout, result: result, options: options}
+}
+
+func (s *searchActionsScreen) Init() tea.Cmd {
+	return nil
+}
+
+func (s *searchActionsScreen) Title() string {
+	return s.result.Title
+}
+
+func (s *searchActionsScreen) Keys() []key.Binding {
+	return []key.Binding{keys.Up, keys.Down, keys.Select}
+}
+
+func (s *searchActionsScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(msg, keys.Down):
+			if s.choice < len(s.options)-1 {
+				s.choice++
+			}
+		case key.Matches(msg, keys.Up):
+			if s.choice > 0 {
+				s.choice--
+			}
+		case key.Matches(msg, keys.Select):
+			next := urlTool(s.layout, s.options[s.choice].View)
+			return s, pushScreen(next(s.result.URL()))
+		}
+	}
+	return s, nil
+}
+
+func (s *searchActionsScreen) View() string {
+	var b strings.Builder
+	b.WriteString(s.layout.Truncate(infoLabelStyle("URL:")+" "+s.result.URL()) + "\n\n")
+	b.WriteString(fmt.Sprintf(
+		strings.Repeat("%s\n", len(s.options)),
+		destructureOptions(s.options, s.choice)...,
+	))
+	return b.String()
+}
@@ -0,0 +1,406 @@
+package app
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/config"
+)
+
+type Server struct {
+	Queue *Queue
+}
+
+type jobRequest struct {
+	URL    string `json:"url"`
+	Kind   string `json:"kind"`
+	Format string `json:"format"`
+	Lang   string `json:"lang"`
+	Clip   string `json:"clip"`
+	Output string `json:"output"`
+	Live   string `json:"live"`
+	// SponsorBlock is remove, mark or off, empty for the mode of the config
+	SponsorBlock string `json:"sponsorblock"`
+	RateLimit    string `json:"rate_limit"`
+}
+
+// Handler routes:
+//
+//	POST   /jobs               submit a job
+//	GET    /jobs               list jobs
+//	GET    /jobs/{id}          get a job
+//	DELETE /jobs/{id}          cancel a job
+//	POST   /jobs/{id}/cancel   cancel a job
+//	POST   /jobs/{id}/stop     stop a recording, keeping what was recorded
+//	GET    /formats?url=&kind= list the formats of a url
+//	GET    /events[?job=id]    job updates as server-sent events
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jobs", s.handleJobs)
+	mux.HandleFunc("/jobs/", s.handleJob)
+	mux.HandleFunc("/formats", s.handleFormats)
+	mux.HandleFunc("/events", s.handleEvents)
+	return localOnly(mux)
+}
+
+// localOnly turns away requests web pages can make: ones from another origin, and ones to a
+// host name other than localhost, which is how DNS rebinding reaches a local server. IP
+// addresses are fine, a page can't rebind those.
+func localOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !localHost(r.Host) {
+			writeError(w, http.StatusForbidden, "host "+r.Host+" is not allowed, use localhost or an IP address")
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || !localHost(u.Host) {
+				writeError(w, http.StatusForbidden, "requests from "+origin+" are not allowed")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// localHost reports whether host, with or without a port, is localhost or an IP address
+func localHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return strings.EqualFold(host, "localhost") || net.ParseIP(host) != nil
+}
+
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.Queue.Jobs())
+	case http.MethodPost:
+		// a form or text/plain body is what a web page can send without asking first
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+			writeError(w, http.StatusUnsupportedMediaType, "the body must be sent as application/json")
+			return
+		}
+		var req jobRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+		job, err := req.toJob()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, s.Queue.Add(job))
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (r jobRequest) toJob() (*Job, error) {
+	parsed, err := ParseVideoURL(r.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	kind := JobVideo
+	if r.Kind != "" {
+		if kind, err = ParseJobKind(r.Kind); err != nil {
+			return nil, err
+		}
+	}
+
+	switch r.Live {
+	case "", "now", "start":
+	default:
+		return nil, fmt.Errorf("live must be now or start, not %q", r.Live)
+	}
+	if r.Live != "" && kind == JobSubtitles {
+		return nil, fmt.Errorf("subtitles can't be recorded")
+	}
+
+	switch r.SponsorBlock {
+	case "", "remove", "mark", "off":
+	default:
+		return nil, fmt.Errorf("sponsorblock must be remove, mark or off, not %q", r.SponsorBlock)
+	}
+
+	if _, err := config.ParseRate(r.RateLimit); err != nil {
+		return nil, fmt.Errorf("rate_limit: %v", err)
+	}
+
+	if r.Output != "" && !insideDir(r.Output, config.Current().OutputDir) {
+		return nil, fmt.Errorf("output must be in the output folder %s", config.Current().OutputDir)
+	}
+
+	job := &Job{Kind: kind, URL: parsed.String(), Format: r.Format, Lang: r.Lang, Output: r.Output, Live: r.Live, SponsorBlock: r.SponsorBlock, RateLimit: r.RateLimit}
+	if r.Clip != "" {
+		if job.Clip, err = ParseTimeRange(r.Clip); err != nil {
+			return nil, fmt.Errorf("clip: %v", err)
+		}
+	}
+	return job, nil
+}
+
+// insideDir reports whether the files of the output template path are written in dir: path
+// can't be dir itself, and the folder it names has to be dir or one in it
+func insideDir(path string, dir string) bool {
+	rel, ok := relPath(path, dir)
+	if !ok || rel == "." {
+		return false
+	}
+	_, ok = relPath(filepath.Dir(path), dir)
+	return ok
+}
+
+// relPath is path relative to dir once both are made absolute, ok when it doesn't leave dir
+func relPath(path string, dir string) (string, bool) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil || (action != "" && action != "cancel" && action != "stop") {
+		writeError(w, http.StatusNotFound, "no such job")
+		return
+	}
+
+	if action == "stop" {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		job, err := s.Queue.Stop(id)
+		if err != nil {
+			status := http.StatusConflict
+			if job.ID == 0 {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+		return
+	}
+
+	method := r.Method
+	if action == "cancel" {
+		if method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		method = http.MethodDelete
+	}
+
+	switch method {
+	case http.MethodGet:
+		job, ok := s.Queue.Get(id)
+		if !ok {
+			writeError(w, http.StatusNotFound, "no such job")
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	case http.MethodDelete:
+		job, err := s.Queue.Cancel(id)
+		if err != nil {
+			status := http.StatusConflict
+			if job.ID == 0 {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleFormats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	parsed, err := ParseVideoURL(r.URL.Query().Get("url"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	url := parsed.String()
+
+	switch r.URL.Query().Get("kind") {
+	case "audio":
+		formats, err := backend.AudioFormats(url)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, formats)
+	case "subtitles":
+		languages, err := backend.SubtitleLanguages(url)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, languages)
+	case "", "video":
+		formats, err := backend.VideoFormats(url)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, formats)
+	default:
+		writeError(w, http.StatusBadRequest, "kind must be video, audio or subtitles")
+	}
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	filter := 0
+	if v := r.URL.Query().Get("job"); v != "" {
+		var err error
+		if filter, err = strconv.Atoi(v); err != nil {
+			writeError(w, http.StatusBadRequest, "job must be a job id")
+			return
+		}
+	}
+
+	updates, unsubscribe := s.Queue.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// send the current state first so clients don't have to race a GET against the stream
+	for _, job := range s.Queue.Jobs() {
+		if filter == 0 || job.ID == filter {
+			writeEvent(w, job)
+		}
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case job, ok := <-updates:
+			if !ok {
+				return
+			}
+			if filter != 0 && job.ID != filter {
+				continue
+			}
+			writeEvent(w, job)
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, job Job) {
+	data, _ := json.Marshal(job)
+	fmt.Fprintf(w, "event: job\nid: %d\ndata: %s\n\n", job.ID, data)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func RunServeCommand(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", "127.0.0.1:8765", "address to listen on, keep it on localhost unless you trust the network")
+	concurrency := fs.Int("concurrency", config.Current().Concurrency, "number of downloads to run at once")
+	report := fs.String("report", config.Current().Report, "file to write a report of the jobs to when stopped, .csv or .json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *concurrency < 1 || *concurrency > config.MaxConcurrency {
+		fmt.Fprintf(os.Stderr, "bubly serve: -concurrency must be between 1 and %d\n", config.MaxConcurrency)
+		return 2
+	}
+
+	if !backend.YtdlpInstalled() {
+		fmt.Fprintln(os.Stderr, "bubly serve: yt-dlp was not found in bin/, run bubly once to install it")
+		return 1
+	}
+	LoadExtractors()
+
+	host, _, err := net.SplitHostPort(*addr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bubly serve:", err)
+		return 2
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		fmt.Fprintf(os.Stderr, "bubly serve: warning, listening on %s exposes the API without authentication\n", host)
+	}
+
+	queue := NewQueue(*concurrency)
+	server := &Server{Queue: queue}
+	httpServer := &http.Server{Addr: *addr, Handler: server.Handler()}
+
+	// ctrl+c or a kill stops listening, so that the report gets written
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		httpServer.Close()
+	}()
+
+	fmt.Printf("Bubly API listening on http://%s\n", *addr)
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		fmt.Fprintln(os.Stderr, "bubly serve:", err)
+		return 1
+	}
+
+	if path, err := SaveReport(*report, NewReport(queue.Jobs())); err != nil {
+		fmt.Fprintln(os.Stderr, "bubly serve:", err)
+		return 1
+	} else if path != "" {
+		fmt.Println("Report saved to", path)
+	}
+	return 0
+}
@@ -0,0 +1,27 @@
+package app
+
+import "testing"
+
+func TestInsideDir(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "assets/clip", want: true},
+		{path: "assets/%(title)s [%(id)s]", want: true},
+		{path: "assets/music/%(title)s", want: true},
+		{path: "assets", want: false},
+		{path: "assets/", want: false},
+		{path: "assets/music/..", want: false},
+		{path: "assets/..", want: false},
+		{path: "assets/../clip", want: false},
+		{path: "assets/%(title)s/../../clip", want: false},
+		{path: "other/clip", want: false},
+		{path: "/tmp/clip", want: false},
+	}
+	for _, tt := range tests {
+		if got := insideDir(tt.path, "assets"); got != tt.want {
+			t.Errorf("insideDir(%q, \"assets\") = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
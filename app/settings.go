@@ -0,0 +1,745 @@
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/config"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type settingKind int
+
+const (
+	settingText settingKind = iota
+	settingSelect
+	settingToggle
+	settingDir
+)
+
+// setting is one row of the settings screen. set checks the value before storing it, so a
+// value that is rejected never makes it into the config.
+type setting struct {
+	Label   string
+	Hint    string
+	Kind    settingKind
+	options func(cfg *config.Config) []string
+	get     func(cfg *config.Config) string
+	set     func(cfg *config.Config, value string) error
+}
+
+var settings = []setting{
+	{
+		Label: "Output folder",
+		Hint:  "Downloads go into subfolders of it, like audio and chapters",
+		Kind:  settingDir,
+		get:   func(cfg *config.Config) string { return cfg.OutputDir },
+		set: func(cfg *config.Config, value string) error {
+			if strings.TrimSpace(value) == "" {
+				return fmt.Errorf("the output folder can't be empty")
+			}
+			if info, err := os.Stat(value); err == nil && !info.IsDir() {
+				return fmt.Errorf("%s is a file, not a folder", value)
+			}
+			cfg.OutputDir = value
+			return nil
+		},
+	},
+	{
+		Label: "Page size",
+		Hint:  "Items per page, 0 fits pages to the window",
+		Kind:  settingText,
+		get:   func(cfg *config.Config) string { return strconv.Itoa(cfg.PageSize) },
+		set: func(cfg *config.Config, value string) error {
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || n < 0 {
+				return fmt.Errorf("the page size must be a number, 0 or more")
+			}
+			cfg.PageSize = n
+			return nil
+		},
+	},
+	{
+		Label: "Concurrent downloads",
+		Hint:  "Downloads run at once, lowering it takes effect on the next start",
+		Kind:  settingText,
+		get:   func(cfg *config.Config) string { return strconv.Itoa(cfg.Concurrency) },
+		set: func(cfg *config.Config, value string) error {
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || n < 1 || n > config.MaxConcurrency {
+				return fmt.Errorf("concurrent downloads must be a number from 1 to %d", config.MaxConcurrency)
+			}
+			cfg.Concurrency = n
+			return nil
+		},
+	},
+	{
+		Label: "Default audio format",
+		Hint:  "yt-dlp format for audio when none is picked, e.g. in batch and sync",
+		Kind:  settingText,
+		get:   func(cfg *config.Config) string { return cfg.AudioFormat },
+		set: func(cfg *config.Config, value string) error {
+			if err := checkFormat(value); err != nil {
+				return err
+			}
+			cfg.AudioFormat = value
+			return nil
+		},
+	},
+	{
+		Label: "Default video format",
+		Hint:  "yt-dlp format for video when none is picked, e.g. in batch and sync",
+		Kind:  settingText,
+		get:   func(cfg *config.Config) string { return cfg.VideoFormat },
+		set: func(cfg *config.Config, value string) error {
+			if err := checkFormat(value); err != nil {
+				return err
+			}
+			cfg.VideoFormat = value
+			return nil
+		},
+	},
+	{
+		Label: "Speed limit",
+		Hint:  "For all downloads together, like 500K or 2M a second, empty for none",
+		Kind:  settingText,
+		get:   func(cfg *config.Config) string { return cfg.RateLimit },
+		set: func(cfg *config.Config, value string) error {
+			value = strings.TrimSpace(value)
+			if _, err := config.ParseRate(value); err != nil {
+				return err
+			}
+			cfg.RateLimit = value
+			return nil
+		},
+	},
+	{
+		Label: "Download hours",
+		Hint:  "Windows queued downloads wait for, like 22:00-06:00, empty for any time",
+		Kind:  settingText,
+		get:   func(cfg *config.Config) string { return strings.Join(cfg.Schedule, ", ") },
+		set: func(cfg *config.Config, value string) error {
+			var schedule []string
+			for _, window := range strings.Split(value, ",") {
+				window = strings.TrimSpace(window)
+				if window == "" {
+					continue
+				}
+				if _, err := config.ParseWindow(window); err != nil {
+					return err
+				}
+				schedule = append(schedule, window)
+			}
+			cfg.Schedule = schedule
+			return nil
+		},
+	},
+	{
+		Label: "Report file",
+		Hint:  "Where runs list their jobs when they end, .csv or .json, {time} for one per run",
+		Kind:  settingText,
+		get:   func(cfg *config.Config) string { return cfg.Report },
+		set: func(cfg *config.Config, value string) error {
+			cfg.Report = strings.TrimSpace(value)
+			return nil
+		},
+	},
+	{
+		Label:   "Disk space check",
+		Hint:    "block keeps downloads that may not fit from starting, warn only says so",
+		Kind:    settingSelect,
+		options: func(cfg *config.Config) []string { return []string{"block", "warn", "off"} },
+		get: func(cfg *config.Config) string {
+			if cfg.DiskCheck == "" {
+				return "block"
+			}
+			return cfg.DiskCheck
+		},
+		set: func(cfg *config.Config, value string) error {
+			cfg.DiskCheck = value
+			return nil
+		},
+	},
+	{
+		Label:   "SponsorBlock",
+		Hint:    "remove cuts sponsor reads and the like out of downloads, mark makes them chapters",
+		Kind:    settingSelect,
+		options: func(cfg *config.Config) []string { return []string{"off", "remove", "mark"} },
+		get: func(cfg *config.Config) string {
+			if cfg.SponsorBlock.Mode == "" {
+				return "off"
+			}
+			return cfg.SponsorBlock.Mode
+		},
+		set: func(cfg *config.Config, value string) error {
+			cfg.SponsorBlock.Mode = strings.TrimPrefix(value, "off")
+			return nil
+		},
+	},
+	{
+		Label: "SponsorBlock categories",
+		Hint:  "Comma-separated, from " + strings.Join(config.SponsorBlockCategories, ", "),
+		Kind:  settingText,
+		get:   func(cfg *config.Config) string { return strings.Join(cfg.SponsorBlock.CategoryList(), ",") },
+		set: func(cfg *config.Config, value string) error {
+			var categories []string
+			for _, category := range strings.Split(value, ",") {
+				category = strings.TrimSpace(category)
+				if category == "" {
+					continue
+				}
+				if !slices.Contains(config.SponsorBlockCategories, category) {
+					return fmt.Errorf("unknown category %q", category)
+				}
+				categories = append(categories, category)
+			}
+			if len(categories) == 0 {
+				return fmt.Errorf("pick at least one category")
+			}
+			cfg.SponsorBlock.Categories = categories
+			return nil
+		},
+	},
+	{
+		Label: "SponsorBlock API",
+		Hint:  "Server the segments come from, e.g. a local stand-in",
+		Kind:  settingText,
+		get:   func(cfg *config.Config) string { return cfg.SponsorBlock.APIBase() },
+		set: func(cfg *config.Config, value string) error {
+			value = strings.TrimSpace(value)
+			if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("the API must be an http or https URL")
+			}
+			cfg.SponsorBlock.API = value
+			if strings.TrimRight(value, "/") == config.DefaultSponsorBlockAPI {
+				cfg.SponsorBlock.API = ""
+			}
+			return nil
+		},
+	},
+	{
+		Label: "Theme",
+		Hint:  "A built-in theme, or a theme file from bubly.json",
+		Kind:  settingSelect,
+		options: func(cfg *config.Config) []string {
+			names := ThemeNames()
+			if _, ok := builtinThemes[cfg.Theme]; !ok && cfg.Theme != "" {
+				names = append(names, cfg.Theme)
+			}
+			return names
+		},
+		get: func(cfg *config.Config) string {
+			if cfg.Theme == "" {
+				return "default"
+			}
+			return cfg.Theme
+		},
+		set: func(cfg *config.Config, value string) error {
+			if _, err := LoadTheme(value); err != nil {
+				return err
+			}
+			cfg.Theme = value
+			return nil
+		},
+	},
+	{
+		Label:   "Background",
+		Hint:    "auto asks the terminal whether its background is dark",
+		Kind:    settingSelect,
+		options: func(cfg *config.Config) []string { return []string{"auto", "dark", "light"} },
+		get: func(cfg *config.Config) string {
+			if cfg.Background == "" {
+				return "auto"
+			}
+			return cfg.Background
+		},
+		set: func(cfg *config.Config, value string) error {
+			cfg.Background = value
+			return nil
+		},
+	},
+	{
+		Label:   "Keymap",
+		Hint:    "Key layout, single keys can still be rebound in bubly.json",
+		Kind:    settingSelect,
+		options: func(cfg *config.Config) []string { return KeyMapPresets },
+		get: func(cfg *config.Config) string {
+			if cfg.Keymap == "" {
+				return "vim"
+			}
+			return cfg.Keymap
+		},
+		set: func(cfg *config.Config, value string) error {
+			cfg.Keymap = value
+			return nil
+		},
+	},
+	{
+		Label: "Compact layout",
+		Hint:  "Always use the compact layout, not only on small windows",
+		Kind:  settingToggle,
+		get:   func(cfg *config.Config) string { return strconv.FormatBool(cfg.Compact) },
+		set: func(cfg *config.Config, value string) error {
+			cfg.Compact = value == "true"
+			return nil
+		},
+	},
+	{
+		Label: "Terminal bell",
+		Hint:  "Ring the bell when the downloads that were running are all done",
+		Kind:  settingToggle,
+		get:   func(cfg *config.Config) string { return strconv.FormatBool(cfg.Notifications.Bell) },
+		set: func(cfg *config.Config, value string) error {
+			cfg.Notifications.Bell = value == "true"
+			return nil
+		},
+	},
+	{
+		Label:   "Terminal notification",
+		Hint:    "9 for iTerm2, Windows Terminal and WezTerm, 777 for urxvt, foot and Ghostty",
+		Kind:    settingSelect,
+		options: func(cfg *config.Config) []string { return []string{"off", "9", "777"} },
+		get: func(cfg *config.Config) string {
+			if cfg.Notifications.Terminal == "" {
+				return "off"
+			}
+			return cfg.Notifications.Terminal
+		},
+		set: func(cfg *config.Config, value string) error {
+			cfg.Notifications.Terminal = strings.TrimPrefix(value, "off")
+			return nil
+		},
+	},
+	{
+		Label: "Desktop notifications",
+		Hint:  "Notify through the desktop's notification service, on Linux",
+		Kind:  settingToggle,
+		get:   func(cfg *config.Config) string { return strconv.FormatBool(cfg.Notifications.Desktop) },
+		set: func(cfg *config.Config, value string) error {
+			cfg.Notifications.Desktop = value == "true"
+			return nil
+		},
+	},
+	{
+		Label: "Taskbar progress",
+		Hint:  "Show the download progress on the taskbar or tab, where the terminal supports it",
+		Kind:  settingToggle,
+		get:   func(cfg *config.Config) string { return strconv.FormatBool(cfg.Notifications.Progress) },
+		set: func(cfg *config.Config, value string) error {
+			cfg.Notifications.Progress = value == "true"
+			return nil
+		},
+	},
+	{
+		Label:   "Profile",
+		Hint:    "The profile whose cookies are used, --profile still wins",
+		Kind:    settingSelect,
+		options: func(cfg *config.Config) []string { return cfg.ProfileNames() },
+		get:     func(cfg *config.Config) string { return cfg.Profile },
+		set: func(cfg *config.Config, value string) error {
+			cfg.Profile = value
+			return nil
+		},
+	},
+}
+
+func checkFormat(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("the format can't be empty")
+	}
+	if strings.ContainsAny(value, " \t") {
+		return fmt.Errorf("the format can't contain spaces")
+	}
+	return nil
+}
+
+// settingValueMsg brings a value back from the input and folder screens
+type settingValueMsg struct {
+	Index int
+	Value string
+}
+
+// settingsScreen edits the config file as saved, so overrides like --profile or BUBLY_THEME
+// don't end up in it. Changes are kept on the screen until they are saved.
+type settingsScreen struct {
+	layout *Layout
+	cfg    *config.Config
+	choice int
+	dirty  bool
+	err    string
+}
+
+func newSettingsScreen(layout *Layout) *settingsScreen {
+	cfg, err := config.File()
+	s := &settingsScreen{layout: layout, cfg: cfg}
+	if err != nil {
+		s.err = err.Error()
+	}
+	return s
+}
+
+func (s *settingsScreen) Init() tea.Cmd {
+	return nil
+}
+
+func (s *settingsScreen) Title() string {
+	return "Settings ⚙️"
+}
+
+func (s *settingsScreen) Keys() []key.Binding {
+	if s.cfg == nil {
+		return nil
+	}
+	bindings := []key.Binding{keys.Up, keys.Down}
+	switch settings[s.choice].Kind {
+	case settingSelect:
+		bindings = append(bindings, withDesc(keys.PrevPage, "previous"), withDesc(keys.NextPage, "next"))
+	case settingToggle:
+		bindings = append(bindings, withDesc(keys.Select, "toggle"))
+	default:
+		bindings = append(bindings, withDesc(keys.Select, "edit"))
+	}
+	if s.dirty {
+		bindings = append(bindings, keys.Save)
+	}
+	return bindings
+}
+
+func (s *settingsScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
+	if s.cfg == nil {
+		return s, nil
+	}
+
+	switch msg := msg.(type) {
+	case settingValueMsg:
+		s.change(msg.Index, msg.Value)
+	case tea.KeyMsg:
+		field := settings[s.choice]
+		switch {
+		case key.Matches(msg, keys.Down):
+			if len(settings) > s.choice+1 {
+				s.choice++
+			}
+		case key.Matches(msg, keys.Up):
+			if s.choice > 0 {
+				s.choice--
+			}
+		case key.Matches(msg, keys.Save):
+			return s, s.save()
+		case field.Kind == settingSelect && key.Matches(msg, keys.PrevPage):
+			s.cycle(-1)
+		case field.Kind == settingSelect && key.Matches(msg, keys.NextPage, keys.Select):
+			s.cycle(1)
+		case field.Kind == settingToggle && key.Matches(msg, keys.Select, keys.PrevPage, keys.NextPage):
+			s.change(s.choice, strconv.FormatBool(field.get(s.cfg) != "true"))
+		case field.Kind == settingText && key.Matches(msg, keys.Select):
+			return s, pushScreen(newSettingInputScreen(s.layout, s.cfg, s.choice))
+		case field.Kind == settingDir && key.Matches(msg, keys.Select):
+			return s, pushScreen(newDirPickerScreen(s.layout, s.cfg, s.choice))
+		}
+	}
+	return s, nil
+}
+
+func (s *settingsScreen) change(index int, value string) {
+	if settings[index].get(s.cfg) == value {
+		return
+	}
+	if err := settings[index].set(s.cfg, value); err != nil {
+		s.err = err.Error()
+		return
+	}
+	s.err = ""
+	s.dirty = true
+}
+
+func (s *settingsScreen) cycle(step int) {
+	options := settings[s.choice].options(s.cfg)
+	if len(options) == 0 {
+		return
+	}
+	current := 0
+	for i, option := range options {
+		if option == settings[s.choice].get(s.cfg) {
+			current = i
+		}
+	}
+	next := (current + step + len(options)) % len(options)
+	s.change(s.choice, options[next])
+}
+
+// save writes the config and applies it to the running app
+func (s *settingsScreen) save() tea.Cmd {
+	if !s.dirty {
+		return nil
+	}
+	if err := config.Save(s.cfg); err != nil {
+		s.err = err.Error()
+		return nil
+	}
+	s.dirty = false
+	s.err = ""
+
+	cfg := config.Current()
+	s.layout.Configure(cfg.PageSize, cfg.Compact)
+	SharedQueue().Grow(cfg.Concurrency)
+	if err := UseKeyMap(cfg.Keymap, cfg.Keys); err != nil {
+		return warn("Settings saved, but the keys could not be loaded: " + err.Error())
+	}
+	if err := UseTheme(cfg.Theme, cfg.Background); err != nil {
+		return warn("Settings saved, but the theme could not be loaded: " + err.Error())
+	}
+	return warn("Settings saved to " + config.Path())
+}
+
+func (s *settingsScreen) View() string {
+	var b strings.Builder
+	if s.cfg == nil {
+		b.WriteString(ErrorStyle(s.layout.Wrap("Could not read the settings: " + s.err)))
+		return b.String()
+	}
+
+	width := 0
+	for _, field := range settings {
+		if len(field.Label) > width {
+			width = len(field.Label)
+		}
+	}
+
+	start, end := pageBounds(s.choice, len(settings), s.layout.ItemsPerPage)
+	for i := start; i < end; i++ {
+		field := settings[i]
+		cursor := "  "
+		if s.choice == i {
+			cursor = "> "
+		}
+
+		value := field.get(s.cfg)
+		switch field.Kind {
+		case settingSelect:
+			value = "‹ " + value + " ›"
+		case settingToggle:
+			value = "off"
+			if field.get(s.cfg) == "true" {
+				value = "on"
+			}
+		}
+
+		line := fmt.Sprintf("%s%-*s %s", cursor, width, field.Label, subtitleLangStyle(value))
+		b.WriteString(s.layout.Truncate(line) + "\n")
+	}
+	// the page keys cycle through choices here, so the footer has no page keys
+	if pages := (len(settings) + s.layout.ItemsPerPage - 1) / s.layout.ItemsPerPage; pages > 1 {
+		b.WriteString(fmt.Sprintf("\nPage %d of %d\n", s.choice/s.layout.ItemsPerPage+1, pages))
+	}
+
+	b.WriteString("\n" + infoLabelStyle(s.layout.Truncate(settings[s.choice].Hint)))
+	if s.dirty {
+		b.WriteString("\n\n" + infoLabelStyle("Unsaved changes, they are dropped when going back"))
+	}
+	if s.err != "" {
+		b.WriteString("\n" + ErrorStyle(s.layout.Wrap(s.err)))
+	}
+	return b.String()
+}
+
+// settingInputScreen edits a text setting, the value is checked before going back
+type settingInputScreen struct {
+	layout *Layout
+	cfg    *config.Config
+	index  int
+	input  textinput.Model
+	err    string
+}
+
+func newSettingInputScreen(layout *Layout, cfg *config.Config, index int) *settingInputScreen {
+	ti := textinput.New()
+	ti.Prompt = "┃ "
+	ti.CharLimit = 280
+	ti.Width = inputWidth(layout)
+	ti.SetValue(settings[index].get(cfg))
+	ti.Focus()
+
+	return &settingInputScreen{layout: layout, cfg: cfg, index: index, input: ti}
+}
+
+func (s *settingInputScreen) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (s *settingInputScreen) Title() string {
+	return settings[s.index].Label
+}
+
+func (s *settingInputScreen) Keys() []key.Binding {
+	return []key.Binding{withDesc(keys.Select, "apply"), withDesc(keys.Back, "cancel when empty")}
+}
+
+func (s *settingInputScreen) Typing() bool {
+	return true
+}
+
+func (s *settingInputScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		s.input.Width = inputWidth(s.layout)
+		return s, nil
+	case tea.KeyMsg:
+		switch {
+		case typedKey(msg):
+		case key.Matches(msg, keys.Select):
+			// checked on a copy, the settings screen stores it once it's back
+			scratch := *s.cfg
+			value := strings.TrimSpace(s.input.Value())
+			if err := settings[s.index].set(&scratch, value); err != nil {
+				s.err = err.Error()
+				return s, nil
+			}
+			return s, popWithResult(settingValueMsg{Index: s.index, Value: value})
+		case key.Matches(msg, keys.Back):
+			if s.input.Value() == "" {
+				return s, popScreen()
+			}
+		}
+	}
+
+	var cmd tea.Cmd
+	s.input, cmd = s.input.Update(msg)
+	return s, cmd
+}
+
+func (s *settingInputScreen) View() string {
+	var b strings.Builder
+	b.WriteString(s.layout.Truncate(settings[s.index].Hint) + "\n")
+	b.WriteString("\n" + s.input.View() + "\n")
+	if s.err != "" {
+		b.WriteString("\n" + ErrorStyle(s.layout.Wrap(s.err)) + "\n")
+	}
+	return b.String()
+}
+
+// dirPickerScreen browses folders for the output folder setting
+type dirPickerScreen struct {
+	layout  *Layout
+	index   int
+	dir     string
+	entries []string
+	choice  int
+	err     string
+}
+
+func newDirPickerScreen(layout *Layout, cfg *config.Config, index int) *dirPickerScreen {
+	s := &dirPickerScreen{layout: layout, index: index}
+	// the output folder may not exist yet, start from the closest parent that does
+	s.open(closestDir(settings[index].get(cfg)))
+	return s
+}
+
+func (s *dirPickerScreen) open(dir string) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		s.err = err.Error()
+		return
+	}
+	files, err := os.ReadDir(abs)
+	if err != nil {
+		s.err = err.Error()
+		return
+	}
+
+	var entries []string
+	if filepath.Dir(abs) != abs {
+		entries = append(entries, "..")
+	}
+	var names []string
+	for _, file := range files {
+		if file.IsDir() && !strings.HasPrefix(file.Name(), ".") {
+			names = append(names, file.Name())
+		}
+	}
+	sort.Strings(names)
+
+	s.dir = abs
+	s.entries = append(entries, names...)
+	s.choice = 0
+	s.err = ""
+}
+
+func (s *dirPickerScreen) Init() tea.Cmd {
+	return nil
+}
+
+func (s *dirPickerScreen) Title() string {
+	return "Choose folder"
+}
+
+func (s *dirPickerScreen) Keys() []key.Binding {
+	bindings := listKeys(len(s.entries), s.layout.ItemsPerPage)
+	if len(s.entries) > 0 {
+		bindings = append(bindings, withDesc(keys.Select, "open"))
+	}
+	return append(bindings, withDesc(keys.Save, "use this folder"))
+}
+
+func (s *dirPickerScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return s, nil
+	}
+	if movePage(keyMsg, &s.choice, len(s.entries), s.layout.ItemsPerPage) {
+		return s, nil
+	}
+	switch {
+	case key.Matches(keyMsg, keys.Select):
+		if len(s.entries) > 0 {
+			s.open(filepath.Join(s.dir, s.entries[s.choice]))
+		}
+	case key.Matches(keyMsg, keys.Save):
+		return s, popWithResult(settingValueMsg{Index: s.index, Value: relativeDir(s.dir)})
+	}
+	return s, nil
+}
+
+func (s *dirPickerScreen) View() string {
+	var b strings.Builder
+	b.WriteString(s.layout.Truncate("📁 "+s.dir) + "\n\n")
+
+	if len(s.entries) == 0 {
+		b.WriteString("No folders in here\n")
+	}
+	start, end := pageBounds(s.choice, len(s.entries), s.layout.ItemsPerPage)
+	for i := start; i < end; i++ {
+		cursor := "  "
+		if s.choice == i {
+			cursor = "> "
+		}
+		b.WriteString(s.layout.Truncate(cursor+subtitleLangStyle(s.entries[i]+string(filepath.Separator))) + "\n")
+	}
+	b.WriteString(pageFooter(s.choice, len(s.entries), s.layout.ItemsPerPage))
+
+	if s.err != "" {
+		b.WriteString("\n" + ErrorStyle(s.layout.Wrap(s.err)))
+	}
+	return b.String()
+}
+
+// relativeDir keeps folders inside the working directory relative, like the default assets
+func relativeDir(dir string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return dir
+	}
+	rel, err := filepath.Rel(wd, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return dir
+	}
+	return rel
+}
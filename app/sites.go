@@ -0,0 +1,184 @@
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Site is the yt-dlp extractor that handles a URL
+type Site struct {
+	// Name is the extractor's name in lower case, which is also the key of its settings
+	Name string
+	// Label is the name shown, like "Vimeo"
+	Label string
+	// Generic is set when no extractor matched, yt-dlp then tries its generic one
+	Generic bool
+}
+
+// siteLabels are the sites whose extractor names don't read well capitalized
+var siteLabels = map[string]string{
+	"youtube":     "YouTube",
+	"soundcloud":  "SoundCloud",
+	"tiktok":      "TikTok",
+	"bilibili":    "BiliBili",
+	"dailymotion": "Dailymotion",
+	"twitter":     "X (Twitter)",
+	"peertube":    "PeerTube",
+}
+
+// siteHosts are the hosts whose names aren't their extractor's
+var siteHosts = map[string]string{
+	"youtu.be": "youtube",
+	"x.com":    "twitter",
+	"fb.watch": "facebook",
+	"redd.it":  "reddit",
+	"b23.tv":   "bilibili",
+}
+
+// siteLimits are the menu entries a site has nothing for, audio sites have no video to pick
+// formats or subtitles of
+var siteLimits = map[string][]string{
+	"soundcloud": {"download-video", "download-subtitles", "chapters"},
+	"bandcamp":   {"download-video", "download-subtitles", "chapters"},
+	"mixcloud":   {"download-video", "download-subtitles", "chapters"},
+	"audiomack":  {"download-video", "download-subtitles", "chapters"},
+}
+
+// extractors are the names of yt-dlp's extractors in lower case, loaded once per session
+var extractors = struct {
+	sync.Mutex
+	names  map[string]bool
+	loaded bool
+	once   sync.Once
+}{}
+
+type extractorsLoadedMsg struct{}
+
+func loadExtractors() tea.Cmd {
+	extractors.Lock()
+	loaded := extractors.loaded
+	extractors.Unlock()
+	if loaded {
+		return nil
+	}
+	return func() tea.Msg {
+		LoadExtractors()
+		return extractorsLoadedMsg{}
+	}
+}
+
+// LoadExtractors loads the extractor list the first time it's called. The app loads it from
+// the URL screen, batch, sync and serve before their first download.
+func LoadExtractors() {
+	extractors.once.Do(func() {
+		names, err := backend.Extractors()
+		extractors.Lock()
+		defer extractors.Unlock()
+		// without the list sites are still guessed from their host, so there's no retry
+		extractors.loaded = true
+		if err == nil {
+			extractors.names = map[string]bool{}
+			for _, name := range names {
+				extractors.names[name] = true
+			}
+		}
+	})
+}
+
+// FetchExtractors lists yt-dlp's extractors, only the part of the names before the ":" that
+// some have for their playlist or channel variants
+func FetchExtractors() ([]string, error) {
+	path := "bin/yt-dlp"
+	if isWindows() {
+		path = "bin/yt-dlp.exe"
+	}
+	out, err := exec.Command(path, "--list-extractors").Output()
+	if err != nil {
+		return nil, fmt.Errorf("Error listing extractors: %v", err)
+	}
+	return parseExtractors(string(out)), nil
+}
+
+// parseExtractors reads the output of --list-extractors, where broken extractors are marked
+// after their name
+func parseExtractors(output string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.Contains(line, "CURRENTLY BROKEN") {
+			continue
+		}
+		name := strings.ToLower(strings.SplitN(fields[0], ":", 2)[0])
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// DetectSite finds the extractor of a URL from its host, trying its labels from the right
+// so that player.vimeo.com and www.vimeo.com are both vimeo
+func DetectSite(rawURL string) Site {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return Site{Generic: true}
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if youtubeHosts[host] || youtubeHosts["www."+host] {
+		return newSite("youtube")
+	}
+	if name, ok := siteHosts[host]; ok {
+		return newSite(name)
+	}
+
+	labels := strings.Split(host, ".")
+	extractors.Lock()
+	names := extractors.names
+	extractors.Unlock()
+	if names == nil {
+		// the list isn't there, guessing from the labels would make bbc.co.uk "co"
+		return Site{Name: host, Label: host}
+	}
+	for i := len(labels) - 2; i >= 0; i-- {
+		if names[labels[i]] {
+			return newSite(labels[i])
+		}
+	}
+	return Site{Name: "generic", Label: host, Generic: true}
+}
+
+func newSite(name string) Site {
+	if name == "" {
+		return Site{Name: "generic", Label: "Generic", Generic: true}
+	}
+	label, ok := siteLabels[name]
+	if !ok {
+		label = strings.ToUpper(name[:1]) + name[1:]
+	}
+	return Site{Name: name, Label: label}
+}
+
+// Supports is false for the menu entries the site has nothing for
+func (s Site) Supports(view string) bool {
+	for _, limit := range siteLimits[s.Name] {
+		if limit == view {
+			return false
+		}
+	}
+	return true
+}
+
+// siteOf is the site of a video, from the page yt-dlp settled on when it's known
+func siteOf(url string, info *VideoInfo) Site {
+	if info != nil && info.WebpageURL != "" {
+		return DetectSite(info.WebpageURL)
+	}
+	return DetectSite(url)
+}
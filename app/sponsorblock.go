@@ -0,0 +1,178 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/config"
+	"github.com/AbdelilahOu/Bubly-cli-app/utils"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// SponsorSegment is a part of a video the SponsorBlock community marked, in seconds
+type SponsorSegment struct {
+	Category string     `json:"category"`
+	Segment  [2]float64 `json:"segment"`
+}
+
+func (s SponsorSegment) Length() float64 {
+	return s.Segment[1] - s.Segment[0]
+}
+
+var sponsorBlockClient = &http.Client{Timeout: 10 * time.Second}
+
+// FetchSponsorSegments asks the configured SponsorBlock server for the segments of a YouTube
+// video in the configured categories. A video nobody submitted segments for has none.
+func FetchSponsorSegments(videoID string) ([]SponsorSegment, error) {
+	cfg := config.Current().SponsorBlock
+	categories, _ := json.Marshal(cfg.CategoryList())
+	query := url.Values{"videoID": {videoID}, "categories": {string(categories)}}
+
+	resp, err := sponsorBlockClient.Get(cfg.APIBase() + "/api/skipSegments?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("Error asking SponsorBlock: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("SponsorBlock answered %s", resp.Status)
+	}
+	var segments []SponsorSegment
+	if err := json.NewDecoder(resp.Body).Decode(&segments); err != nil {
+		return nil, fmt.Errorf("Error reading SponsorBlock segments: %v", err)
+	}
+	return segments, nil
+}
+
+type SponsorSegmentsMsg struct {
+	URL      string
+	Segments []SponsorSegment
+	Error    string
+}
+
+// fetchSponsorSegments is nil for URLs that aren't YouTube videos, SponsorBlock has no others
+func fetchSponsorSegments(rawURL string) tea.Cmd {
+	parsed, err := ParseVideoURL(rawURL)
+	if err != nil || parsed.ID == "" {
+		return nil
+	}
+	return func() tea.Msg {
+		segments, err := backend.SponsorSegments(parsed.ID)
+		if err != nil {
+			return SponsorSegmentsMsg{URL: rawURL, Error: err.Error()}
+		}
+		return SponsorSegmentsMsg{URL: rawURL, Segments: segments}
+	}
+}
+
+// sponsorBlockMode is what a job does with the segments, "" when nothing
+func sponsorBlockMode(job *Job) string {
+	mode := job.SponsorBlock
+	if mode == "" {
+		mode = config.Current().SponsorBlock.Mode
+	}
+	if mode == "off" {
+		return ""
+	}
+	return mode
+}
+
+// sponsorBlockArgs has yt-dlp cut out or mark the segments as chapters, cutting needs ffmpeg
+func sponsorBlockArgs(job *Job) []string {
+	mode := sponsorBlockMode(job)
+	if mode == "" {
+		return nil
+	}
+	cfg := config.Current().SponsorBlock
+	return []string{"--sponsorblock-api", cfg.APIBase(), "--sponsorblock-" + mode, strings.Join(cfg.CategoryList(), ",")}
+}
+
+// nextSponsorBlockMode is the mode after mode when cycling through them on a screen
+func nextSponsorBlockMode(mode string) string {
+	switch mode {
+	case "remove":
+		return "mark"
+	case "mark":
+		return "off"
+	default:
+		return "remove"
+	}
+}
+
+// sponsorBlockSummary is e.g. "sponsor 1:02, selfpromo 0:15 (1:17 in total)"
+func sponsorBlockSummary(segments []SponsorSegment) string {
+	lengths := map[string]float64{}
+	var total float64
+	for _, segment := range segments {
+		lengths[segment.Category] += segment.Length()
+		total += segment.Length()
+	}
+	categories := make([]string, 0, len(lengths))
+	for category := range lengths {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool { return lengths[categories[i]] > lengths[categories[j]] })
+
+	parts := make([]string, len(categories))
+	for i, category := range categories {
+		parts[i] = category + " " + utils.FormatDuration(lengths[category])
+	}
+	return strings.Join(parts, ", ") + " (" + utils.FormatDuration(total) + " in total)"
+}
+
+// sponsorBlockView is the SponsorBlock line of the format screens
+type sponsorBlockView struct {
+	mode     string
+	segments []SponsorSegment
+	loaded   bool
+	err      string
+}
+
+// newSponsorBlockView is nil for URLs that aren't YouTube videos
+func newSponsorBlockView(rawURL string) *sponsorBlockView {
+	if parsed, err := ParseVideoURL(rawURL); err != nil || parsed.ID == "" {
+		return nil
+	}
+	mode := config.Current().SponsorBlock.Mode
+	if mode == "" {
+		mode = "off"
+	}
+	return &sponsorBlockView{mode: mode}
+}
+
+func (v *sponsorBlockView) update(msg SponsorSegmentsMsg) {
+	v.loaded = true
+	v.segments = msg.Segments
+	v.err = msg.Error
+}
+
+func (v *sponsorBlockView) View(layout *Layout) string {
+	mode := "off"
+	switch v.mode {
+	case "remove":
+		mode = "cut out"
+	case "mark":
+		mode = "as chapters"
+	}
+
+	line := "⏭ SponsorBlock (" + mode + "): "
+	switch {
+	case v.err != "":
+		line += v.err
+	case !v.loaded:
+		line += "looking up segments " + spinnerFrame()
+	case len(v.segments) == 0:
+		line += "no segments for this video"
+	default:
+		line += sponsorBlockSummary(v.segments)
+	}
+	return layout.Truncate(line) + "\n\n"
+}
@@ -0,0 +1,513 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/config"
+	"github.com/AbdelilahOu/Bubly-cli-app/utils"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const subscriptionsFile = "subscriptions.json"
+
+type Subscription struct {
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Kind      JobKind   `json:"kind"`
+	Format    string    `json:"format,omitempty"`
+	OutputDir string    `json:"output_dir"`
+	DateAfter string    `json:"date_after,omitempty"`
+	LastSync  time.Time `json:"last_sync"`
+	LastNew   int       `json:"last_new"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+func (s *Subscription) ArchivePath() string {
+	return filepath.Join(s.OutputDir, "archive.txt")
+}
+
+// syncs run in the background, so every read-modify-write of the file goes through this lock
+var subscriptionsMu sync.Mutex
+
+func LoadSubscriptions() ([]Subscription, error) {
+	subscriptionsMu.Lock()
+	defer subscriptionsMu.Unlock()
+	return loadSubscriptions()
+}
+
+func loadSubscriptions() ([]Subscription, error) {
+	data, err := os.ReadFile(subscriptionsFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var subs []Subscription
+	if err := json.Unmarshal(data, &subs); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", subscriptionsFile, err)
+	}
+	return subs, nil
+}
+
+func saveSubscriptions(subs []Subscription) error {
+	data, err := json.MarshalIndent(subs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(subscriptionsFile, append(data, '\n'), 0644)
+}
+
+func updateSubscriptions(fn func([]Subscription) ([]Subscription, error)) error {
+	subscriptionsMu.Lock()
+	defer subscriptionsMu.Unlock()
+
+	subs, err := loadSubscriptions()
+	if err != nil {
+		return err
+	}
+	subs, err = fn(subs)
+	if err != nil {
+		return err
+	}
+	return saveSubscriptions(subs)
+}
+
+func AddSubscription(sub Subscription) error {
+	return updateSubscriptions(func(subs []Subscription) ([]Subscription, error) {
+		for _, existing := range subs {
+			if existing.Name == sub.Name {
+				return nil, fmt.Errorf("a subscription named %q already exists", sub.Name)
+			}
+		}
+		return append(subs, sub), nil
+	})
+}
+
+func RemoveSubscription(name string) error {
+	return updateSubscriptions(func(subs []Subscription) ([]Subscription, error) {
+		for i, sub := range subs {
+			if sub.Name == name {
+				return append(subs[:i], subs[i+1:]...), nil
+			}
+		}
+		return nil, fmt.Errorf("no subscription named %q", name)
+	})
+}
+
+func recordSync(name string, newItems int, syncErr error) error {
+	return updateSubscriptions(func(subs []Subscription) ([]Subscription, error) {
+		for i := range subs {
+			if subs[i].Name == name {
+				subs[i].LastSync = time.Now()
+				subs[i].LastNew = newItems
+				subs[i].LastError = ""
+				if syncErr != nil {
+					subs[i].LastError = syncErr.Error()
+				}
+			}
+		}
+		return subs, nil
+	})
+}
+
+// SyncSubscription downloads everything that is not in the source's download archive yet
+// and returns the files of the new items
+func SyncSubscription(sub Subscription) ([]string, error) {
+	os.MkdirAll(sub.OutputDir, 0755)
+
+	var path, ffmpegPath string
+	if isWindows() {
+		path = "bin/yt-dlp.exe"
+		ffmpegPath = "bin/ffmpeg.exe"
+	} else {
+		path = "bin/yt-dlp"
+		ffmpegPath = "bin/ffmpeg"
+	}
+
+	logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating log file: %v", err)
+	}
+	defer logFile.Close()
+
+	var outBuf, errBuf strings.Builder
+
+	_, err = os.Stat(ffmpegPath)
+	useFfmpeg := err == nil
+
+	var args []string
+	switch sub.Kind {
+	case JobAudio:
+		format := sub.Format
+		if format == "" {
+			format = config.Current().AudioFormatFor("youtube")
+		}
+		args = append(args, "-f", format, "-x", "--audio-quality", "0")
+	default:
+		format := sub.Format
+		if format == "" {
+			format = config.Current().VideoFormatFor("youtube")
+		}
+		args = append(args, "-f", format)
+	}
+
+	if useFfmpeg {
+		args = append(args, "--ffmpeg-location", ffmpegPath)
+	}
+
+	if sub.DateAfter != "" {
+		args = append(args, "--dateafter", sub.DateAfter)
+	}
+
+	// syncs follow the config, like a job without settings of its own on a single slot
+	args = append(args, sponsorBlockArgs(&Job{})...)
+	args = append(args, rateLimitArgs(rateLimit(&Job{}, 1))...)
+	args = append(args, "--download-archive", sub.ArchivePath(), "--ignore-errors")
+	args = append(args, "--sleep-requests", "1", "--sleep-interval", "5", "--max-sleep-interval", "10")
+	args = append(args, "--print", "after_move:filepath", "--no-quiet")
+	args = append(args, "-o", filepath.Join(templateEscape(sub.OutputDir), "%(upload_date)s - %(title)s [%(id)s].%(ext)s"), sub.URL)
+
+	cmd := ytdlpCommand(path, args...)
+	cmd.Stdout = io.MultiWriter(&outBuf, logFile)
+	cmd.Stderr = io.MultiWriter(&errBuf, logFile)
+	err = cmd.Run()
+
+	var files []string
+	for _, line := range strings.Split(outBuf.String(), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "[") {
+			if _, statErr := os.Stat(line); statErr == nil {
+				files = append(files, line)
+			}
+		}
+	}
+
+	// --ignore-errors still exits non-zero when a single item failed
+	if err != nil && len(files) == 0 {
+		return nil, ytdlpError("syncing "+sub.Name, err, errBuf.String())
+	}
+	return files, nil
+}
+
+// CountPending lists the source without downloading and counts ids missing from the archive.
+// The date filter is not applied since flat listings carry no upload dates.
+func CountPending(sub Subscription) (int, error) {
+	var path string
+	if isWindows() {
+		path = "bin/yt-dlp.exe"
+	} else {
+		path = "bin/yt-dlp"
+	}
+
+	var outBuf, errBuf strings.Builder
+	cmd := ytdlpCommand(path, "--flat-playlist", "--print", "id", sub.URL)
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	if err := cmd.Run(); err != nil {
+		return 0, ytdlpError("listing "+sub.Name, err, errBuf.String())
+	}
+
+	archived := map[string]bool{}
+	if file, err := os.Open(sub.ArchivePath()); err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 2 {
+				archived[fields[1]] = true
+			}
+		}
+		file.Close()
+	}
+
+	pending := 0
+	for _, id := range strings.Fields(outBuf.String()) {
+		if !archived[id] {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func syncSubscription(sub Subscription) tea.Cmd {
+	return func() tea.Msg {
+		files, err := SyncSubscription(sub)
+		recordSync(sub.Name, len(files), err)
+		msg := SubscriptionSyncMsg{Name: sub.Name, New: len(files)}
+		if err != nil {
+			msg.Error = err.Error()
+		}
+		return msg
+	}
+}
+
+func checkSubscription(sub Subscription) tea.Cmd {
+	return func() tea.Msg {
+		pending, err := CountPending(sub)
+		msg := SubscriptionCheckMsg{Name: sub.Name, Pending: pending}
+		if err != nil {
+			msg.Error = err.Error()
+		}
+		return msg
+	}
+}
+
+type SubscriptionSelection struct {
+	Subscriptions []Subscription
+	Choice        int
+	Syncing       map[string]bool
+	Checking      map[string]bool
+	Pending       map[string]int
+	Errors        map[string]string
+}
+
+func newSubscriptionSelection() (*SubscriptionSelection, error) {
+	subs, err := LoadSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+	return &SubscriptionSelection{
+		Subscriptions: subs,
+		Syncing:       map[string]bool{},
+		Checking:      map[string]bool{},
+		Pending:       map[string]int{},
+		Errors:        map[string]string{},
+	}, nil
+}
+
+type SubscriptionSyncMsg struct {
+	Name  string
+	New   int
+	Error string
+}
+
+type SubscriptionCheckMsg struct {
+	Name    string
+	Pending int
+	Error   string
+}
+
+func RunSyncCommand(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "add":
+			return runSyncAdd(args[1:])
+		case "remove":
+			if len(args) != 2 {
+				fmt.Fprintln(os.Stderr, "Usage: bubly sync remove <name>")
+				return 2
+			}
+			if err := RemoveSubscription(args[1]); err != nil {
+				fmt.Fprintln(os.Stderr, "bubly sync:", err)
+				return 1
+			}
+			fmt.Println("Removed", args[1])
+			return 0
+		case "list":
+			return runSyncList(args[1:])
+		case "-h", "--help", "help":
+			printSyncUsage()
+			return 0
+		}
+	}
+
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	fs.Usage = printSyncUsage
+	report := fs.String("report", config.Current().Report, "file to write a report of the syncs to, .csv or .json")
+	jsonOut := fs.Bool("json", false, "print the report as JSON on stdout, progress goes to stderr")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	out := os.Stdout
+	if *jsonOut {
+		out = os.Stderr
+	}
+
+	subs, err := LoadSubscriptions()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bubly sync:", err)
+		return 1
+	}
+	if len(subs) == 0 {
+		fmt.Fprintln(os.Stderr, "bubly sync: no subscriptions yet, add one with `bubly sync add <url>`")
+		return 1
+	}
+	LoadExtractors()
+
+	if !utils.CheckYtdlp() {
+		fmt.Fprintln(os.Stderr, "bubly sync: yt-dlp was not found in bin/, run bubly once to install it")
+		return 1
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range fs.Args() {
+		wanted[name] = true
+	}
+
+	code := 0
+	var results []ReportEntry
+	for _, sub := range subs {
+		if len(wanted) > 0 && !wanted[sub.Name] {
+			continue
+		}
+		delete(wanted, sub.Name)
+
+		fmt.Fprintf(out, "▶ %s (%s)\n", sub.Name, sub.URL)
+		started := time.Now()
+		files, err := SyncSubscription(sub)
+		recordSync(sub.Name, len(files), err)
+		results = append(results, syncReportEntry(len(results)+1, sub, files, err, started))
+		if err != nil {
+			fmt.Fprintf(out, "  ✗ %v\n", err)
+			code = 1
+			continue
+		}
+		fmt.Fprintf(out, "  ✓ %d new items in %s\n", len(files), sub.OutputDir)
+	}
+
+	for name := range wanted {
+		fmt.Fprintf(os.Stderr, "bubly sync: no subscription named %q\n", name)
+		code = 1
+	}
+
+	if *jsonOut {
+		WriteReportJSON(os.Stdout, results)
+	}
+	if path, err := SaveReport(*report, results); err != nil {
+		fmt.Fprintln(os.Stderr, "bubly sync:", err)
+		code = 1
+	} else if path != "" {
+		fmt.Fprintln(out, "Report saved to", path)
+	}
+	return code
+}
+
+// syncReportEntry reports a sync like a job, titled with the name of the subscription
+func syncReportEntry(id int, sub Subscription, files []string, err error, started time.Time) ReportEntry {
+	job := Job{
+		ID:       id,
+		Kind:     sub.Kind,
+		URL:      sub.URL,
+		Title:    sub.Name,
+		Format:   sub.Format,
+		Files:    files,
+		Status:   JobDone,
+		Started:  started,
+		Finished: time.Now(),
+	}
+	if err != nil {
+		job.Status, job.Error, job.ErrorClass = JobFailed, err.Error(), errorClass(err)
+	}
+	return NewReportEntry(job)
+}
+
+func printSyncUsage() {
+	fmt.Println(`Usage:
+  bubly sync [flags] [name...]  download new items for all or the named subscriptions
+  bubly sync add [flags] <url>  subscribe to a channel or playlist
+  bubly sync list [-json]       show subscriptions and their last sync
+  bubly sync remove <name>      unsubscribe
+
+Flags of sync:
+  -json           print a report as JSON on stdout, progress goes to stderr
+  -report <file>  write a report to file, .csv or .json`)
+}
+
+func runSyncAdd(args []string) int {
+	fs := flag.NewFlagSet("sync add", flag.ContinueOnError)
+	name := fs.String("name", "", "name of the subscription (defaults to the channel or playlist id)")
+	kind := fs.String("kind", string(JobVideo), "what to download: video or audio")
+	format := fs.String("format", "", "yt-dlp format id or selector")
+	output := fs.String("output", "", "output folder (defaults to subscriptions/<name> in the output folder)")
+	after := fs.String("after", "", "only download items uploaded on or after this date (YYYYMMDD or e.g. today-2weeks)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: bubly sync add [flags] <url>")
+		return 2
+	}
+
+	sourceURL, err := ParseSourceURL(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bubly sync:", err)
+		return 1
+	}
+
+	subKind, err := ParseJobKind(*kind)
+	if err != nil || subKind == JobSubtitles {
+		fmt.Fprintln(os.Stderr, "bubly sync: -kind must be video or audio")
+		return 2
+	}
+
+	sub := Subscription{
+		Name:      *name,
+		URL:       sourceURL,
+		Kind:      subKind,
+		Format:    *format,
+		OutputDir: *output,
+		DateAfter: *after,
+	}
+	if sub.Name == "" {
+		sub.Name = sourceName(sourceURL)
+	}
+	if sub.OutputDir == "" {
+		sub.OutputDir = outputPath("subscriptions", utils.SanitizeFilename(sub.Name))
+	}
+
+	if err := AddSubscription(sub); err != nil {
+		fmt.Fprintln(os.Stderr, "bubly sync:", err)
+		return 1
+	}
+	fmt.Printf("Subscribed to %s as %q, downloads go to %s\n", sub.URL, sub.Name, sub.OutputDir)
+	return 0
+}
+
+func runSyncList(args []string) int {
+	fs := flag.NewFlagSet("sync list", flag.ContinueOnError)
+	jsonOut := fs.Bool("json", false, "print the subscriptions as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	subs, err := LoadSubscriptions()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bubly sync:", err)
+		return 1
+	}
+	if *jsonOut {
+		if subs == nil {
+			subs = []Subscription{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(subs)
+		return 0
+	}
+	for _, sub := range subs {
+		fmt.Printf("%s\n  %s\n  %s → %s, last sync %s, %d new", sub.Name, sub.URL, sub.Kind, sub.OutputDir, utils.FormatAgo(sub.LastSync), sub.LastNew)
+		if sub.LastError != "" {
+			fmt.Printf(", error: %s", sub.LastError)
+		}
+		fmt.Println()
+	}
+	return 0
+}
+
+func sourceName(sourceURL string) string {
+	if _, list, ok := strings.Cut(sourceURL, "list="); ok {
+		return list
+	}
+	segments := strings.Split(strings.TrimPrefix(sourceURL, "https://www.youtube.com/"), "/")
+	return strings.TrimPrefix(segments[len(segments)-2], "@")
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -24,83 +23,66 @@ type SubtitleSelection struct {
 	Done        bool
 	Error       bool
 	ErrMsg      string
+	// JobID is the job of the queue downloading the subtitles
+	JobID int
 }
 
-func (m AppModel) fetchSubtitleLanguages(url string) tea.Cmd {
+func fetchSubtitleLanguages(url string) tea.Cmd {
 	return func() tea.Msg {
-
-		os.MkdirAll("assets", 0755)
-
-		var path, ffmpegPath string
-		if isWindows() {
-			path = "bin/yt-dlp.exe"
-			ffmpegPath = "bin/ffmpeg.exe"
-		} else {
-			path = "bin/yt-dlp"
-			ffmpegPath = "bin/ffmpeg"
-		}
-
-		logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		languages, err := backend.SubtitleLanguages(url)
 		if err != nil {
-			return SubtitleLangMsg{Error: fmt.Sprintf("Error creating log file: %v", err)}
+			return SubtitleLangMsg{URL: url, Error: err.Error()}
 		}
-		defer logFile.Close()
+		return SubtitleLangMsg{URL: url, Languages: languages}
+	}
+}
 
-		var outBuf, errBuf strings.Builder
+func FetchSubtitleLanguages(url string) ([]SubtitleLanguage, error) {
+	os.MkdirAll(outputPath(), 0755)
 
-		_, err = os.Stat(ffmpegPath)
-		useFfmpeg := err == nil
+	var path, ffmpegPath string
+	if isWindows() {
+		path = "bin/yt-dlp.exe"
+		ffmpegPath = "bin/ffmpeg.exe"
+	} else {
+		path = "bin/yt-dlp"
+		ffmpegPath = "bin/ffmpeg"
+	}
 
-		var args []string
-		args = append(args, "--list-subs", url)
+	logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating log file: %v", err)
+	}
+	defer logFile.Close()
 
-		if useFfmpeg {
-			args = append(args, "--ffmpeg-location", ffmpegPath)
-		} else {
-			// Add a warning to the log file if ffmpeg is not found
-			fmt.Fprintf(logFile, "Warning: ffmpeg not found. Some features may not work correctly.\n")
-		}
+	var outBuf, errBuf strings.Builder
 
-		cmd := exec.Command(path, args...)
-		cmd.Stdout = io.MultiWriter(&outBuf, logFile)
-		cmd.Stderr = io.MultiWriter(&errBuf, logFile)
-
-		err = cmd.Run()
-
-		debugFile, _ := os.OpenFile("debug.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		if debugFile != nil {
-			defer debugFile.Close()
-			fmt.Fprintf(debugFile, "Fetching subtitle languages for URL: %s\n", url)
-			fmt.Fprintf(debugFile, "Command executed, err: %v\n", err)
-			if err == nil {
-				output := outBuf.String()
-				fmt.Fprintf(debugFile, "Output length: %d\n", len(output))
-
-				if len(output) > 1000 {
-					fmt.Fprintf(debugFile, "Output (first 1000 chars): %s\n", output[:1000])
-				} else {
-					fmt.Fprintf(debugFile, "Output: %s\n", output)
-				}
-			} else {
-				fmt.Fprintf(debugFile, "Error output: %s\n", errBuf.String())
-			}
-		}
+	_, err = os.Stat(ffmpegPath)
+	useFfmpeg := err == nil
 
-		if err != nil {
-			return SubtitleLangMsg{Error: fmt.Sprintf("Error fetching subtitle languages: %v. Check output.log for details.", err)}
-		}
+	var args []string
+	args = append(args, "--list-subs", url)
 
-		languages := ParseSubtitleLanguages(outBuf.String())
+	if useFfmpeg {
+		args = append(args, "--ffmpeg-location", ffmpegPath)
+	} else {
+		// Add a warning to the log file if ffmpeg is not found
+		fmt.Fprintf(logFile, "Warning: ffmpeg not found. Some features may not work correctly.\n")
+	}
 
-		if debugFile != nil {
-			fmt.Fprintf(debugFile, "Parsed %d subtitle languages\n", len(languages))
-			for i, l := range languages {
-				fmt.Fprintf(debugFile, "Language %d: Code=%s, Name=%s\n", i, l.Code, l.Name)
-			}
-		}
+	cmd := ytdlpCommand(path, args...)
+	cmd.Stdout = io.MultiWriter(&outBuf, logFile)
+	cmd.Stderr = io.MultiWriter(&errBuf, logFile)
 
-		return SubtitleLangMsg{URL: url, Languages: languages}
+	err = cmd.Run()
+
+	if err != nil {
+		return nil, ytdlpError("fetching subtitle languages", err, errBuf.String())
 	}
+
+	languages := ParseSubtitleLanguages(outBuf.String())
+
+	return languages, nil
 }
 
 func ParseSubtitleLanguages(output string) []SubtitleLanguage {
@@ -111,7 +93,8 @@ func ParseSubtitleLanguages(output string) []SubtitleLanguage {
 
 	for _, line := range lines {
 
-		if strings.Contains(line, "Available automatic captions for") {
+		// sites without automatic captions only list the uploaded subtitles
+		if strings.Contains(line, "Available automatic captions for") || strings.Contains(line, "Available subtitles for") {
 			parsingSubtitles = true
 			continue
 		}
@@ -120,7 +103,7 @@ func ParseSubtitleLanguages(output string) []SubtitleLanguage {
 			strings.Contains(line, "Language Name") ||
 			strings.Contains(line, "----") ||
 			strings.TrimSpace(line) == "" ||
-			strings.Contains(line, "[youtube]") {
+			strings.HasPrefix(line, "[") {
 			continue
 		}
 
@@ -199,60 +182,92 @@ func ParseSubtitleLanguages(output string) []SubtitleLanguage {
 	return languages
 }
 
-func (m AppModel) downloadSubtitles(url string, langCode string) tea.Cmd {
+// downloadSubtitles waits for a job of the shared queue
+func downloadSubtitles(id int) tea.Cmd {
 	return func() tea.Msg {
+		job, _ := SharedQueue().WaitFor(id)
+		if job.Status != JobDone {
+			return SubtitleDownloadMsg{JobID: id, Error: job.Error}
+		}
+		return SubtitleDownloadMsg{JobID: id, Done: true, Files: job.Files}
+	}
+}
 
-		os.MkdirAll("assets", 0755)
+func doDownloadSubtitles(job *Job) error {
+	os.MkdirAll(outputPath(), 0755)
 
-		var path, ffmpegPath string
-		if isWindows() {
-			path = "bin/yt-dlp.exe"
-			ffmpegPath = "bin/ffmpeg.exe"
-		} else {
-			path = "bin/yt-dlp"
-			ffmpegPath = "bin/ffmpeg"
-		}
+	var path, ffmpegPath string
+	if isWindows() {
+		path = "bin/yt-dlp.exe"
+		ffmpegPath = "bin/ffmpeg.exe"
+	} else {
+		path = "bin/yt-dlp"
+		ffmpegPath = "bin/ffmpeg"
+	}
 
-		logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		if err != nil {
-			return SubtitleDownloadMsg{Error: fmt.Sprintf("Error creating log file: %v", err)}
-		}
-		defer logFile.Close()
+	logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("Error creating log file: %v", err)
+	}
+	defer logFile.Close()
 
-		var outBuf, errBuf strings.Builder
+	var outBuf, errBuf strings.Builder
+	// deferred so that downloads failing halfway through still have their title
+	defer func() { job.reportTitle(printedTitle(outBuf.String())) }()
 
-		_, err = os.Stat(ffmpegPath)
-		useFfmpeg := err == nil
+	_, err = os.Stat(ffmpegPath)
+	useFfmpeg := err == nil
 
-		var args []string
-		args = append(args, "--write-sub", "--write-auto-sub", "--sub-lang", langCode, "--skip-download")
+	lang := job.Lang
+	if lang == "" {
+		lang = "en"
+	}
 
-		if useFfmpeg {
-			args = append(args, "--ffmpeg-location", ffmpegPath)
-		} else {
-			// Add a warning to the log file if ffmpeg is not found
-			fmt.Fprintf(logFile, "Warning: ffmpeg not found. Some features may not work correctly.\n")
-		}
+	var args []string
+	args = append(args, "--write-sub", "--write-auto-sub", "--sub-lang", lang, "--skip-download")
 
-		args = append(args, "--sleep-requests", "1", "--sleep-interval", "5", "--max-sleep-interval", "10")
-		args = append(args, "-o", "assets/subtitles.%(ext)s", url)
+	if useFfmpeg {
+		args = append(args, "--ffmpeg-location", ffmpegPath)
+	} else {
+		// Add a warning to the log file if ffmpeg is not found
+		fmt.Fprintf(logFile, "Warning: ffmpeg not found. Some features may not work correctly.\n")
+	}
 
-		cmd := exec.Command(path, args...)
-		cmd.Stdout = io.MultiWriter(&outBuf, logFile)
-		cmd.Stderr = io.MultiWriter(&errBuf, logFile)
-		err = cmd.Run()
+	args = append(args, rateLimitArgs(job.rate)...)
+	args = append(args, titleArgs...)
+	// the files are read from the "Writing video subtitles" lines
+	args = append(args, "--no-quiet")
+	args = append(args, "--sleep-requests", "1", "--sleep-interval", "5", "--max-sleep-interval", "10", "--newline")
+	args = append(args, "-o", job.OutputName(outputPath(defaultOutputName))+".%(ext)s", job.URL)
 
-		if err != nil {
-			errorOutput := errBuf.String()
+	cmd := job.command(path, args...)
+	cmd.Stdout = io.MultiWriter(&outBuf, logFile, job.progressWriter())
+	cmd.Stderr = io.MultiWriter(&errBuf, logFile)
+	err = cmd.Run()
 
-			if strings.Contains(errorOutput, "429") || strings.Contains(errorOutput, "Too Many Requests") {
-				return SubtitleDownloadMsg{Error: "Rate limited by YouTube. Please try again later."}
-			}
-			return SubtitleDownloadMsg{Error: fmt.Sprintf("Error downloading subtitles: %v. Check output.log for details.", err)}
+	if err != nil {
+		errorOutput := errBuf.String()
+
+		if strings.Contains(errorOutput, "429") || strings.Contains(errorOutput, "Too Many Requests") {
+			return &JobError{Class: "rate_limited", Message: "Rate limited by the site. Please try again later."}
 		}
+		return ytdlpError("downloading subtitles", err, errBuf.String())
+	}
 
-		return SubtitleDownloadMsg{Done: true}
+	job.reportFiles(subtitleFiles(outBuf.String()))
+	return nil
+}
+
+// subtitleFiles finds the files in yt-dlp's "[info] Writing video subtitles to: FILE" lines,
+// --print has nothing to print for them since the video itself is skipped
+func subtitleFiles(output string) []string {
+	var files []string
+	for _, line := range strings.Split(output, "\n") {
+		if _, file, ok := strings.Cut(line, "subtitles to: "); ok && strings.HasPrefix(line, "[info]") {
+			files = append(files, strings.TrimSpace(file))
+		}
 	}
+	return files
 }
 
 type SubtitleLangMsg struct {
@@ -262,6 +277,8 @@ type SubtitleLangMsg struct {
 }
 
 type SubtitleDownloadMsg struct {
+	JobID int
 	Done  bool
 	Error string
+	Files []string
 }
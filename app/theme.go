@@ -0,0 +1,237 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Palette is the set of colors the styles are built from. Colors are anything lipgloss.Color
+// takes, a hex color like "#7D56F4" or an ANSI code like "241". An empty color means none,
+// badges without a background are drawn in reverse video instead.
+type Palette struct {
+	// Text is the foreground of badges like the title bar and the status messages
+	Text     string `json:"text"`
+	Accent   string `json:"accent"`
+	Success  string `json:"success"`
+	Error    string `json:"error"`
+	Warning  string `json:"warning"`
+	Info     string `json:"info"`
+	Muted    string `json:"muted"`
+	Faint    string `json:"faint"`
+	Selected string `json:"selected"`
+}
+
+// Theme has a palette for dark and one for light terminal backgrounds
+type Theme struct {
+	Dark  Palette `json:"dark"`
+	Light Palette `json:"light"`
+}
+
+var builtinThemes = map[string]Theme{
+	"default": {
+		Dark: Palette{
+			Text:     "#FAFAFA",
+			Accent:   "#7D56F4",
+			Success:  "#16a34a",
+			Error:    "#b91c1c",
+			Warning:  "#f97316",
+			Info:     "#2563eb",
+			Muted:    "241",
+			Faint:    "236",
+			Selected: "212",
+		},
+		Light: Palette{
+			Text:     "#FAFAFA",
+			Accent:   "#874BFD",
+			Success:  "#16a34a",
+			Error:    "#b91c1c",
+			Warning:  "#f97316",
+			Info:     "#2563eb",
+			Muted:    "241",
+			Faint:    "250",
+			Selected: "162",
+		},
+	},
+	"high-contrast": {
+		Dark: Palette{
+			Text:     "#000000",
+			Accent:   "#00FFFF",
+			Success:  "#00FF00",
+			Error:    "#FF5555",
+			Warning:  "#FFFF00",
+			Info:     "#55AAFF",
+			Muted:    "#FFFFFF",
+			Faint:    "#AAAAAA",
+			Selected: "#FFFF00",
+		},
+		Light: Palette{
+			Text:     "#FFFFFF",
+			Accent:   "#00008B",
+			Success:  "#006400",
+			Error:    "#8B0000",
+			Warning:  "#8B4500",
+			Info:     "#00008B",
+			Muted:    "#000000",
+			Faint:    "#555555",
+			Selected: "#8B008B",
+		},
+	},
+	"monochrome": {},
+}
+
+func ThemeNames() []string {
+	var names []string
+	for name := range builtinThemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadTheme returns a built-in theme by name, or reads a theme file. A name that isn't a path
+// is looked up as themes/NAME.json. Colors missing from the file keep the default theme's,
+// and a file with only a dark palette uses it for light backgrounds too.
+func LoadTheme(name string) (Theme, error) {
+	if name == "" {
+		name = "default"
+	}
+	if theme, ok := builtinThemes[name]; ok {
+		return theme, nil
+	}
+
+	path := name
+	if !strings.HasSuffix(name, ".json") && !strings.ContainsAny(name, `/\`) {
+		path = filepath.Join("themes", name+".json")
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Theme{}, fmt.Errorf("theme %q is neither built in (%s) nor a file at %s", name, strings.Join(ThemeNames(), ", "), path)
+	}
+	if err != nil {
+		return Theme{}, err
+	}
+
+	var file struct {
+		Dark  json.RawMessage `json:"dark"`
+		Light json.RawMessage `json:"light"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return Theme{}, fmt.Errorf("reading theme %s: %v", path, err)
+	}
+	theme := builtinThemes["default"]
+	if len(file.Dark) > 0 {
+		if err := json.Unmarshal(file.Dark, &theme.Dark); err != nil {
+			return Theme{}, fmt.Errorf("reading theme %s: %v", path, err)
+		}
+	}
+	if len(file.Light) == 0 {
+		theme.Light = theme.Dark
+	} else if err := json.Unmarshal(file.Light, &theme.Light); err != nil {
+		return Theme{}, fmt.Errorf("reading theme %s: %v", path, err)
+	}
+	return theme, nil
+}
+
+// NoColor reports whether colors are unwanted, through NO_COLOR (https://no-color.org/),
+// CLICOLOR=0 or a dumb terminal
+func NoColor() bool {
+	return termenv.EnvNoColor() || os.Getenv("TERM") == "dumb"
+}
+
+// UseTheme builds the styles from a theme. background is "light", "dark" or "auto", which asks
+// the terminal for its background color. Without colors the theme is ignored for monochrome.
+func UseTheme(name string, background string) error {
+	if NoColor() {
+		lipgloss.SetColorProfile(termenv.Ascii)
+		applyPalette(builtinThemes["monochrome"].Dark)
+		return nil
+	}
+
+	theme, err := LoadTheme(name)
+	if err != nil {
+		return err
+	}
+
+	switch background {
+	case "dark":
+		applyPalette(theme.Dark)
+	case "light":
+		applyPalette(theme.Light)
+	case "", "auto":
+		if lipgloss.HasDarkBackground() {
+			applyPalette(theme.Dark)
+		} else {
+			applyPalette(theme.Light)
+		}
+	default:
+		return fmt.Errorf("background must be light, dark or auto, not %q", background)
+	}
+	return nil
+}
+
+func init() {
+	applyPalette(builtinThemes["default"].Dark)
+}
+
+// applyPalette rebuilds every style in the app from p
+func applyPalette(p Palette) {
+	fg := func(color string) lipgloss.Style {
+		return lipgloss.NewStyle().Foreground(lipgloss.Color(color))
+	}
+	badge := func(bg string) lipgloss.Style {
+		if bg == "" {
+			return lipgloss.NewStyle().Reverse(true)
+		}
+		return lipgloss.NewStyle().Foreground(lipgloss.Color(p.Text)).Background(lipgloss.Color(bg))
+	}
+	banner := func(bg string) func(...string) string {
+		return badge(bg).Align(lipgloss.Left).Margin(1, 1, 0, 0).Padding(0, 2).Render
+	}
+
+	TitleStyle = banner(p.Accent)
+	SuccessStyle = banner(p.Success)
+	ErrorStyle = banner(p.Error)
+	WarningStyle = banner(p.Warning)
+
+	audioQualityStyle = fg(p.Accent).Padding(0, 1).Render
+	audioFormatStyle = badge(p.Success).Padding(0, 1).Render
+	audioFileSizeStyle = fg(p.Warning).Padding(0, 1).Render
+
+	videoQualityStyle = fg(p.Accent).Padding(0, 1).Render
+	videoFormatStyle = badge(p.Success).Padding(0, 1).Render
+	videoResolutionStyle = fg(p.Warning).Padding(0, 1).Render
+	videoFileSizeStyle = fg(p.Info).Padding(0, 1).Render
+
+	subtitleLangStyle = fg(p.Accent).Padding(0, 1).Render
+	subtitleSelectedStyle = badge(p.Success).Padding(0, 1).Render
+
+	chapterNumberStyle = fg(p.Warning).Render
+	chapterTitleStyle = fg(p.Accent).Padding(0, 1).Render
+	chapterTimeStyle = fg(p.Info).Padding(0, 1).Render
+
+	urlErrorStyle = fg(p.Error).PaddingLeft(2).Render
+
+	infoPanelStyle = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color(p.Accent)).Padding(0, 1).Render
+	infoTitleStyle = lipgloss.NewStyle().Bold(true).Render
+	infoLabelStyle = fg(p.Muted).Render
+
+	helpView.Styles.ShortKey = fg(p.Muted).Bold(true)
+	helpView.Styles.ShortDesc = fg(p.Muted)
+	helpView.Styles.ShortSeparator = fg(p.Faint)
+	helpView.Styles.Ellipsis = fg(p.Faint)
+	helpView.Styles.FullKey = fg(p.Accent).Bold(true)
+	helpView.Styles.FullDesc = fg(p.Muted)
+	helpView.Styles.FullSeparator = fg(p.Faint)
+
+	selectedStyle = fg(p.Selected).Render
+	if p.Selected == "" {
+		selectedStyle = lipgloss.NewStyle().Bold(true).Render
+	}
+}
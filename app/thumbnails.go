@@ -0,0 +1,180 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var ThumbnailConversions = []ViewsOptions{
+	{
+		View:        "",
+		ChoiceLabel: "Keep as WebP",
+	},
+	{
+		View:        "jpg",
+		ChoiceLabel: "Convert to JPEG",
+	},
+	{
+		View:        "png",
+		ChoiceLabel: "Convert to PNG",
+	},
+}
+
+type ThumbnailSelection struct {
+	URL         string
+	Thumbnails  []Thumbnail
+	Choice      int
+	Selected    bool
+	Downloading bool
+	Done        bool
+	Error       bool
+	ErrMsg      string
+	File        string
+}
+
+func (t Thumbnail) Resolution() string {
+	if t.Width > 0 && t.Height > 0 {
+		return fmt.Sprintf("%dx%d", t.Width, t.Height)
+	}
+	return "Unknown resolution"
+}
+
+func (t Thumbnail) Ext() string {
+	u, err := url.Parse(t.URL)
+	if err != nil {
+		return "jpg"
+	}
+	ext := strings.TrimPrefix(strings.ToLower(path.Ext(u.Path)), ".")
+	if ext == "" || ext == "jpeg" {
+		return "jpg"
+	}
+	return ext
+}
+
+func ParseThumbnails(info *VideoInfo) []Thumbnail {
+	var thumbnails []Thumbnail
+	seen := map[string]bool{}
+
+	for _, t := range info.Thumbnails {
+		if t.URL == "" || seen[t.URL] {
+			continue
+		}
+		seen[t.URL] = true
+		thumbnails = append(thumbnails, t)
+	}
+
+	sort.SliceStable(thumbnails, func(i, j int) bool {
+		iArea := thumbnails[i].Width * thumbnails[i].Height
+		jArea := thumbnails[j].Width * thumbnails[j].Height
+		if iArea == jArea {
+			return thumbnails[i].Preference > thumbnails[j].Preference
+		}
+		return iArea > jArea
+	})
+
+	return thumbnails
+}
+
+func downloadThumbnail(thumb Thumbnail, convertTo string) tea.Cmd {
+	return func() tea.Msg {
+		file, err := backend.SaveThumbnail(thumb, convertTo)
+		if err != nil {
+			return ThumbnailDownloadMsg{URL: thumb.URL, File: file, Error: err.Error()}
+		}
+		return ThumbnailDownloadMsg{URL: thumb.URL, File: file}
+	}
+}
+
+func SaveThumbnail(thumb Thumbnail, convertTo string) (string, error) {
+	os.MkdirAll(outputPath(), 0755)
+
+	resp, err := http.Get(thumb.URL)
+	if err != nil {
+		return "", fmt.Errorf("Error downloading thumbnail: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Error downloading thumbnail: %s", resp.Status)
+	}
+
+	name := thumbnailName(thumb)
+	destPath := name + "." + thumb.Ext()
+
+	out, err := os.Create(destPath)
+	if err != nil {
+		return "", fmt.Errorf("Error creating file: %v", err)
+	}
+
+	_, err = io.Copy(out, resp.Body)
+	out.Close()
+	if err != nil {
+		return "", fmt.Errorf("Error saving thumbnail: %v", err)
+	}
+
+	if convertTo == "" {
+		return destPath, nil
+	}
+
+	convertedPath := name + "." + convertTo
+	if err := convertImage(destPath, convertedPath); err != nil {
+		return destPath, err
+	}
+	os.Remove(destPath)
+
+	return convertedPath, nil
+}
+
+// thumbnailName is the path a thumbnail is saved to, without the extension
+func thumbnailName(thumb Thumbnail) string {
+	name := outputPath("thumbnail")
+	if thumb.Width > 0 && thumb.Height > 0 {
+		name += "_" + thumb.Resolution()
+	}
+	return name
+}
+
+func convertImage(src, dest string) error {
+	var ffmpegPath string
+	if isWindows() {
+		ffmpegPath = "bin/ffmpeg.exe"
+	} else {
+		ffmpegPath = "bin/ffmpeg"
+	}
+
+	if _, err := os.Stat(ffmpegPath); err != nil {
+		ffmpegPath, err = exec.LookPath("ffmpeg")
+		if err != nil {
+			return fmt.Errorf("Converting thumbnails requires ffmpeg, the original was kept at %s", src)
+		}
+	}
+
+	logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("Error creating log file: %v", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(ffmpegPath, "-y", "-loglevel", "error", "-i", src, dest)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Error converting thumbnail: %v. Check output.log for details.", err)
+	}
+	return nil
+}
+
+type ThumbnailDownloadMsg struct {
+	URL   string
+	File  string
+	Error string
+}
@@ -0,0 +1,166 @@
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/utils"
+)
+
+type TimeRange struct {
+	Start  float64 `json:"start"`
+	End    float64 `json:"end,omitempty"`
+	HasEnd bool    `json:"has_end,omitempty"`
+}
+
+var durationParamRe = regexp.MustCompile(`^(?:(\d+)h)?(?:(\d+)m)?(?:(\d+)s?)?$`)
+
+// ParseTimestamp accepts ss, mm:ss and hh:mm:ss as well as the 1h2m3s form used by t= parameters
+func ParseTimestamp(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty timestamp")
+	}
+
+	if strings.Contains(s, ":") {
+		parts := strings.Split(s, ":")
+		if len(parts) > 3 {
+			return 0, fmt.Errorf("invalid timestamp %q, use mm:ss or hh:mm:ss", s)
+		}
+		var total float64
+		for i, part := range parts {
+			v, err := strconv.ParseFloat(part, 64)
+			if err != nil || v < 0 {
+				return 0, fmt.Errorf("invalid timestamp %q, use mm:ss or hh:mm:ss", s)
+			}
+			if i > 0 && v >= 60 {
+				return 0, fmt.Errorf("invalid timestamp %q, minutes and seconds must be below 60", s)
+			}
+			total = total*60 + v
+		}
+		return total, nil
+	}
+
+	if v, err := strconv.ParseFloat(s, 64); err == nil && v >= 0 {
+		return v, nil
+	}
+
+	matches := durationParamRe.FindStringSubmatch(strings.ToLower(s))
+	if matches == nil {
+		return 0, fmt.Errorf("invalid timestamp %q, use mm:ss or hh:mm:ss", s)
+	}
+	var total float64
+	for i, unit := range []float64{3600, 60, 1} {
+		if matches[i+1] != "" {
+			v, _ := strconv.Atoi(matches[i+1])
+			total += float64(v) * unit
+		}
+	}
+	return total, nil
+}
+
+// ParseTimeRange accepts "start-end", "start-", "-end" or a single start time.
+// A pasted URL with a t= parameter is read as a start time.
+func ParseTimeRange(s string) (*TimeRange, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	if strings.Contains(s, "://") || strings.Contains(s, "?") {
+		start, ok := StartFromURL(s)
+		if !ok {
+			return nil, fmt.Errorf("no t= start time found in that URL")
+		}
+		return &TimeRange{Start: start}, nil
+	}
+
+	startStr, endStr, found := strings.Cut(s, "-")
+	r := &TimeRange{}
+
+	if strings.TrimSpace(startStr) != "" {
+		start, err := ParseTimestamp(startStr)
+		if err != nil {
+			return nil, err
+		}
+		r.Start = start
+	}
+
+	if found && strings.TrimSpace(endStr) != "" {
+		end, err := ParseTimestamp(endStr)
+		if err != nil {
+			return nil, err
+		}
+		if end <= r.Start {
+			return nil, fmt.Errorf("end time must be after start time")
+		}
+		r.End = end
+		r.HasEnd = true
+	}
+
+	return r, nil
+}
+
+func StartFromURL(rawURL string) (float64, bool) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return 0, false
+	}
+
+	values := u.Query()
+	if fragment, err := url.ParseQuery(u.Fragment); err == nil {
+		for k, v := range fragment {
+			values[k] = append(values[k], v...)
+		}
+	}
+
+	for _, key := range []string{"t", "start", "time_continue"} {
+		if v := values.Get(key); v != "" {
+			if start, err := ParseTimestamp(v); err == nil && start > 0 {
+				return start, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (r *TimeRange) Validate(duration float64) error {
+	if duration <= 0 {
+		return nil
+	}
+	if r.Start >= duration {
+		return fmt.Errorf("start %s is past the end of the video (%s)", utils.FormatDuration(r.Start), utils.FormatDuration(duration))
+	}
+	if r.HasEnd && r.End > duration {
+		return fmt.Errorf("end %s is past the end of the video (%s)", utils.FormatDuration(r.End), utils.FormatDuration(duration))
+	}
+	return nil
+}
+
+// Section is the value passed to yt-dlp's --download-sections
+func (r *TimeRange) Section() string {
+	if r.HasEnd {
+		return fmt.Sprintf("*%.3f-%.3f", r.Start, r.End)
+	}
+	return fmt.Sprintf("*%.3f-inf", r.Start)
+}
+
+func (r *TimeRange) String() string {
+	end := "end"
+	if r.HasEnd {
+		end = utils.FormatDuration(r.End)
+	}
+	return utils.FormatDuration(r.Start) + " → " + end
+}
+
+// FileLabel is a filename-safe form of the range, e.g. 1-30_to_2-45
+func (r *TimeRange) FileLabel() string {
+	end := "end"
+	if r.HasEnd {
+		end = utils.FormatDuration(r.End)
+	}
+	return strings.ReplaceAll(utils.FormatDuration(r.Start)+"_to_"+end, ":", "-")
+}
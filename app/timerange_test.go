@@ -0,0 +1,79 @@
+package app
+
+import "testing"
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+		err   bool
+	}{
+		{input: "90", want: 90},
+		{input: "1.5", want: 1.5},
+		{input: "1:30", want: 90},
+		{input: "01:02:03", want: 3723},
+		{input: " 2:05 ", want: 125},
+		{input: "1h2m3s", want: 3723},
+		{input: "2m", want: 120},
+		{input: "45s", want: 45},
+		{input: "1H", want: 3600},
+		{input: "", err: true},
+		{input: "1:60", err: true},
+		{input: "1:2:3:4", err: true},
+		{input: "-5", err: true},
+		{input: "1:-5", err: true},
+		{input: "abc", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTimestamp(tt.input)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseTimestamp(%q) = %v, want an error", tt.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseTimestamp(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseTimestamp(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseTimeRange(t *testing.T) {
+	tests := []struct {
+		input string
+		want  *TimeRange
+		err   bool
+	}{
+		{input: "", want: nil},
+		{input: "1:30-2:45", want: &TimeRange{Start: 90, End: 165, HasEnd: true}},
+		{input: "1:30-", want: &TimeRange{Start: 90}},
+		{input: "-2:45", want: &TimeRange{End: 165, HasEnd: true}},
+		{input: "30", want: &TimeRange{Start: 30}},
+		{input: "https://youtu.be/jNQXAC9IVRw?t=1m5s", want: &TimeRange{Start: 65}},
+		{input: "https://www.youtube.com/watch?v=jNQXAC9IVRw#t=12", want: &TimeRange{Start: 12}},
+		{input: "https://www.youtube.com/watch?v=jNQXAC9IVRw", err: true},
+		{input: "2:00-1:00", err: true},
+		{input: "1:00-1:00", err: true},
+		{input: "1:00-x", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTimeRange(tt.input)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseTimeRange(%q) = %+v, want an error", tt.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseTimeRange(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("ParseTimeRange(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type VideoURL struct {
+	ID       string
+	Playlist string
+	Index    string
+	Start    string
+	// Raw is the URL of a video on any other site than YouTube, passed to yt-dlp as it is
+	Raw string
+}
+
+var videoIDRe = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+
+var youtubeHosts = map[string]bool{
+	"youtube.com":              true,
+	"www.youtube.com":          true,
+	"m.youtube.com":            true,
+	"music.youtube.com":        true,
+	"youtu.be":                 true,
+	"youtube-nocookie.com":     true,
+	"www.youtube-nocookie.com": true,
+}
+
+// ParseVideoURL validates what was typed into the url textarea before yt-dlp is spawned.
+// For YouTube only the video id, playlist and start time survive, tracking parameters are
+// dropped. Links to other sites are left to yt-dlp's extractors.
+func ParseVideoURL(input string) (*VideoURL, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, fmt.Errorf("Enter a video URL or an 11 character YouTube video ID")
+	}
+
+	if videoIDRe.MatchString(input) {
+		return &VideoURL{ID: input}, nil
+	}
+
+	if !strings.Contains(input, "://") {
+		input = "https://" + input
+	}
+
+	u, err := url.Parse(input)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("That doesn't look like a URL")
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if !strings.Contains(host, ".") || strings.Contains(host, "..") || strings.HasPrefix(host, ".") || strings.HasSuffix(host, ".") {
+		return nil, fmt.Errorf("That doesn't look like a URL or a video ID")
+	}
+	if !youtubeHosts[host] {
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("Only http and https links can be downloaded")
+		}
+		return &VideoURL{Raw: u.String()}, nil
+	}
+
+	query := u.Query()
+	v := &VideoURL{
+		Playlist: query.Get("list"),
+		Index:    query.Get("index"),
+		Start:    query.Get("t"),
+	}
+	if v.Start == "" {
+		v.Start = query.Get("start")
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case host == "youtu.be":
+		v.ID = segments[0]
+	case segments[0] == "watch":
+		v.ID = query.Get("v")
+	case segments[0] == "shorts" || segments[0] == "live" || segments[0] == "embed" || segments[0] == "v":
+		if len(segments) > 1 {
+			v.ID = segments[1]
+		}
+	case segments[0] == "playlist":
+		if v.Playlist == "" {
+			return nil, fmt.Errorf("Playlist link is missing its list= parameter")
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("Unsupported YouTube link, use a watch, youtu.be, shorts, live or embed URL")
+	}
+
+	if v.ID == "" {
+		return nil, fmt.Errorf("The link is missing a video ID")
+	}
+	if !videoIDRe.MatchString(v.ID) {
+		return nil, fmt.Errorf("Video ID %q should be 11 letters, digits, - or _", v.ID)
+	}
+
+	return v, nil
+}
+
+func (v *VideoURL) String() string {
+	if v.Raw != "" {
+		return v.Raw
+	}
+	if v.ID == "" {
+		return "https://www.youtube.com/playlist?list=" + url.QueryEscape(v.Playlist)
+	}
+
+	s := "https://www.youtube.com/watch?v=" + v.ID
+	if v.Playlist != "" {
+		s += "&list=" + url.QueryEscape(v.Playlist)
+		if v.Index != "" {
+			s += "&index=" + url.QueryEscape(v.Index)
+		}
+	}
+	if v.Start != "" {
+		s += "&t=" + url.QueryEscape(v.Start)
+	}
+	return s
+}
+
+// ParseSourceURL accepts the channel and playlist links that can be subscribed to.
+// Channel links are pointed at their videos tab unless another tab was given.
+func ParseSourceURL(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", fmt.Errorf("Enter a YouTube channel or playlist URL")
+	}
+	if strings.HasPrefix(input, "@") {
+		input = "https://www.youtube.com/" + input
+	}
+	if !strings.Contains(input, "://") {
+		input = "https://" + input
+	}
+
+	u, err := url.Parse(input)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("That doesn't look like a URL")
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if !youtubeHosts[host] {
+		return "", fmt.Errorf("%s is not a YouTube channel or playlist link", host)
+	}
+	if host == "youtu.be" {
+		return "", fmt.Errorf("youtu.be links point at a single video, use a channel or playlist link")
+	}
+
+	if list := u.Query().Get("list"); list != "" {
+		return (&VideoURL{Playlist: list}).String(), nil
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	var channel []string
+	switch {
+	case strings.HasPrefix(segments[0], "@") && len(segments[0]) > 1:
+		channel = segments[:1]
+	case (segments[0] == "channel" || segments[0] == "c" || segments[0] == "user") && len(segments) > 1:
+		channel = segments[:2]
+	default:
+		return "", fmt.Errorf("Use a channel link (/@handle, /channel/ID) or a playlist link with list=")
+	}
+
+	tab := "videos"
+	if len(segments) > len(channel) {
+		switch segments[len(channel)] {
+		case "videos", "streams", "shorts", "podcasts":
+			tab = segments[len(channel)]
+		}
+	}
+
+	return "https://www.youtube.com/" + strings.Join(channel, "/") + "/" + tab, nil
+}
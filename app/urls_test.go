@@ -0,0 +1,49 @@
+package app
+
+import "testing"
+
+func TestParseVideoURL(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		err   bool
+	}{
+		{input: "jNQXAC9IVRw", want: "https://www.youtube.com/watch?v=jNQXAC9IVRw"},
+		{input: "  https://www.youtube.com/watch?v=jNQXAC9IVRw  ", want: "https://www.youtube.com/watch?v=jNQXAC9IVRw"},
+		{input: "https://www.youtube.com/watch?v=jNQXAC9IVRw&si=tracking&feature=share", want: "https://www.youtube.com/watch?v=jNQXAC9IVRw"},
+		{input: "youtube.com/watch?v=jNQXAC9IVRw", want: "https://www.youtube.com/watch?v=jNQXAC9IVRw"},
+		{input: "https://youtu.be/jNQXAC9IVRw?t=42", want: "https://www.youtube.com/watch?v=jNQXAC9IVRw&t=42"},
+		{input: "https://m.youtube.com/watch?v=jNQXAC9IVRw&start=10", want: "https://www.youtube.com/watch?v=jNQXAC9IVRw&t=10"},
+		{input: "https://www.youtube.com/shorts/jNQXAC9IVRw", want: "https://www.youtube.com/watch?v=jNQXAC9IVRw"},
+		{input: "https://www.youtube.com/live/jNQXAC9IVRw", want: "https://www.youtube.com/watch?v=jNQXAC9IVRw"},
+		{input: "https://www.youtube-nocookie.com/embed/jNQXAC9IVRw", want: "https://www.youtube.com/watch?v=jNQXAC9IVRw"},
+		{input: "https://www.youtube.com/watch?v=jNQXAC9IVRw&list=PL123&index=2", want: "https://www.youtube.com/watch?v=jNQXAC9IVRw&list=PL123&index=2"},
+		{input: "https://www.youtube.com/playlist?list=PL123", want: "https://www.youtube.com/playlist?list=PL123"},
+		{input: "https://vimeo.com/76979871", want: "https://vimeo.com/76979871"},
+		{input: "", err: true},
+		{input: "not a url", err: true},
+		{input: "https://localhost/video", err: true},
+		{input: "https://example..com/video", err: true},
+		{input: "ftp://example.com/video.mp4", err: true},
+		{input: "https://www.youtube.com/playlist", err: true},
+		{input: "https://www.youtube.com/feed/subscriptions", err: true},
+		{input: "https://www.youtube.com/watch?list=PL123", err: true},
+		{input: "https://www.youtube.com/watch?v=tooshort", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseVideoURL(tt.input)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseVideoURL(%q) = %q, want an error", tt.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseVideoURL(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ParseVideoURL(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -4,9 +4,10 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"strings"
 
+	"github.com/AbdelilahOu/Bubly-cli-app/config"
+	"github.com/AbdelilahOu/Bubly-cli-app/utils"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
@@ -27,83 +28,66 @@ type VideoFormatSelection struct {
 	Done        bool
 	Error       bool
 	ErrMsg      string
+	// JobID is the job of the queue downloading the format
+	JobID int
 }
 
-func (m AppModel) fetchVideoFormats(url string) tea.Cmd {
+func fetchVideoFormats(url string) tea.Cmd {
 	return func() tea.Msg {
-
-		os.MkdirAll("assets", 0755)
-
-		var path, ffmpegPath string
-		if isWindows() {
-			path = "bin/yt-dlp.exe"
-			ffmpegPath = "bin/ffmpeg.exe"
-		} else {
-			path = "bin/yt-dlp"
-			ffmpegPath = "bin/ffmpeg"
-		}
-
-		logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		formats, err := backend.VideoFormats(url)
 		if err != nil {
-			return VideoFormatMsg{Error: fmt.Sprintf("Error creating log file: %v", err)}
+			return VideoFormatMsg{URL: url, Error: err.Error()}
 		}
-		defer logFile.Close()
+		return VideoFormatMsg{URL: url, Formats: formats}
+	}
+}
 
-		var outBuf, errBuf strings.Builder
+func FetchVideoFormats(url string) ([]VideoFormat, error) {
+	os.MkdirAll(outputPath(), 0755)
 
-		_, err = os.Stat(ffmpegPath)
-		useFfmpeg := err == nil
+	var path, ffmpegPath string
+	if isWindows() {
+		path = "bin/yt-dlp.exe"
+		ffmpegPath = "bin/ffmpeg.exe"
+	} else {
+		path = "bin/yt-dlp"
+		ffmpegPath = "bin/ffmpeg"
+	}
 
-		var args []string
-		args = append(args, "-F", url)
+	logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating log file: %v", err)
+	}
+	defer logFile.Close()
 
-		if useFfmpeg {
-			args = append(args, "--ffmpeg-location", ffmpegPath)
-		} else {
-			// Add a warning to the log file if ffmpeg is not found
-			fmt.Fprintf(logFile, "Warning: ffmpeg not found. Some features may not work correctly.\n")
-		}
+	var outBuf, errBuf strings.Builder
 
-		cmd := exec.Command(path, args...)
-		cmd.Stdout = io.MultiWriter(&outBuf, logFile)
-		cmd.Stderr = io.MultiWriter(&errBuf, logFile)
-
-		err = cmd.Run()
-
-		debugFile, _ := os.OpenFile("debug.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		if debugFile != nil {
-			defer debugFile.Close()
-			fmt.Fprintf(debugFile, "Fetching video formats for URL: %s\n", url)
-			fmt.Fprintf(debugFile, "Command executed, err: %v\n", err)
-			if err == nil {
-				output := outBuf.String()
-				fmt.Fprintf(debugFile, "Output length: %d\n", len(output))
-
-				if len(output) > 1000 {
-					fmt.Fprintf(debugFile, "Output (first 1000 chars): %s\n", output[:1000])
-				} else {
-					fmt.Fprintf(debugFile, "Output: %s\n", output)
-				}
-			} else {
-				fmt.Fprintf(debugFile, "Error output: %s\n", errBuf.String())
-			}
-		}
+	_, err = os.Stat(ffmpegPath)
+	useFfmpeg := err == nil
 
-		if err != nil {
-			return VideoFormatMsg{Error: fmt.Sprintf("Error fetching formats: %v. Check output.log for details.", err)}
-		}
+	var args []string
+	args = append(args, "-F", url)
 
-		formats := ParseVideoFormats(outBuf.String())
+	if useFfmpeg {
+		args = append(args, "--ffmpeg-location", ffmpegPath)
+	} else {
+		// Add a warning to the log file if ffmpeg is not found
+		fmt.Fprintf(logFile, "Warning: ffmpeg not found. Some features may not work correctly.\n")
+	}
 
-		if debugFile != nil {
-			fmt.Fprintf(debugFile, "Parsed %d video formats\n", len(formats))
-			for i, f := range formats {
-				fmt.Fprintf(debugFile, "Video Format %d: ID=%s, Quality=%s\n", i, f.ID, f.Quality)
-			}
-		}
+	cmd := ytdlpCommand(path, args...)
+	cmd.Stdout = io.MultiWriter(&outBuf, logFile)
+	cmd.Stderr = io.MultiWriter(&errBuf, logFile)
 
-		return VideoFormatMsg{URL: url, Formats: formats}
+	err = cmd.Run()
+
+	if err != nil {
+		return nil, ytdlpError("fetching formats", err, errBuf.String())
 	}
+
+	formats := ParseVideoFormats(outBuf.String())
+
+	return formats, nil
 }
 
 func ParseVideoFormats(output string) []VideoFormat {
@@ -116,7 +100,7 @@ func ParseVideoFormats(output string) []VideoFormat {
 			strings.Contains(line, "ID  EXT") ||
 			strings.Contains(line, "----") ||
 			strings.TrimSpace(line) == "" ||
-			strings.Contains(line, "[youtube]") {
+			strings.HasPrefix(line, "[") {
 			continue
 		}
 
@@ -138,7 +122,7 @@ func ParseVideoFormats(output string) []VideoFormat {
 					resolution = field
 				}
 
-				if strings.Contains(field, "MiB") || strings.Contains(field, "KiB") {
+				if _, ok := utils.ParseSize(field); ok {
 					filesize = field
 				}
 			}
@@ -200,78 +184,111 @@ func ParseVideoFormats(output string) []VideoFormat {
 	return formats
 }
 
-func (m AppModel) downloadVideo(url string, formatID string) tea.Cmd {
+// downloadVideo waits for a job of the shared queue
+func downloadVideo(id int) tea.Cmd {
 	return func() tea.Msg {
-
-		os.MkdirAll("assets", 0755)
-
-		var path, ffmpegPath string
-		if isWindows() {
-			path = "bin/yt-dlp.exe"
-			ffmpegPath = "bin/ffmpeg.exe"
-		} else {
-			path = "bin/yt-dlp"
-			ffmpegPath = "bin/ffmpeg"
+		job, _ := SharedQueue().WaitFor(id)
+		if job.Status != JobDone {
+			return VideoDownloadMsg{JobID: id, Error: job.Error}
 		}
+		return VideoDownloadMsg{JobID: id, Done: true, Files: job.Files}
+	}
+}
 
-		logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		if err != nil {
-			return VideoDownloadMsg{Error: fmt.Sprintf("Error creating log file: %v", err)}
-		}
-		defer logFile.Close()
+func doDownloadVideo(job *Job) error {
+	os.MkdirAll(outputPath(), 0755)
 
-		var outBuf, errBuf strings.Builder
+	var path, ffmpegPath string
+	if isWindows() {
+		path = "bin/yt-dlp.exe"
+		ffmpegPath = "bin/ffmpeg.exe"
+	} else {
+		path = "bin/yt-dlp"
+		ffmpegPath = "bin/ffmpeg"
+	}
 
-		_, err = os.Stat(ffmpegPath)
-		useFfmpeg := err == nil
+	logFile, err := os.OpenFile("output.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("Error creating log file: %v", err)
+	}
+	defer logFile.Close()
 
-		var args []string
-		args = append(args, "-f", formatID)
+	var outBuf, errBuf strings.Builder
+	// deferred so that downloads failing halfway through still have their title
+	defer func() { job.reportTitle(printedTitle(outBuf.String())) }()
 
-		if useFfmpeg {
-			args = append(args, "--ffmpeg-location", ffmpegPath)
-		} else {
-			// Add a warning to the log file if ffmpeg is not found
-			fmt.Fprintf(logFile, "Warning: ffmpeg not found. Some features may not work correctly.\n")
-		}
+	_, err = os.Stat(ffmpegPath)
+	useFfmpeg := err == nil
 
-		args = append(args, "--sleep-requests", "1", "--sleep-interval", "5", "--max-sleep-interval", "10")
-		args = append(args, "-o", "assets/video.%(ext)s", url)
+	formatID := job.Format
+	if formatID == "" {
+		formatID = config.Current().VideoFormatFor(DetectSite(job.URL).Name)
+	}
 
-		cmd := exec.Command(path, args...)
-		cmd.Stdout = io.MultiWriter(&outBuf, logFile)
-		cmd.Stderr = io.MultiWriter(&errBuf, logFile)
-		err = cmd.Run()
+	if !useFfmpeg {
+		// Add a warning to the log file if ffmpeg is not found
+		fmt.Fprintf(logFile, "Warning: ffmpeg not found. Some features may not work correctly.\n")
+	}
 
-		if err != nil {
-			errorOutput := errBuf.String()
+	output := job.OutputName(outputPath(defaultOutputName)) + ".%(ext)s"
+	args := videoArgs(job, formatID, ffmpegPath, useFfmpeg, output)
 
-			if strings.Contains(errorOutput, "403") || strings.Contains(errorOutput, "Forbidden") {
+	cmd := job.command(path, args...)
+	cmd.Stdout = io.MultiWriter(&outBuf, logFile, job.progressWriter())
+	cmd.Stderr = io.MultiWriter(&errBuf, logFile)
+	err = cmd.Run()
 
-				args = []string{"-f", "best"}
+	if err != nil {
+		errorOutput := errBuf.String()
 
-				if useFfmpeg {
-					args = append(args, "--ffmpeg-location", ffmpegPath)
-				}
+		if strings.Contains(errorOutput, "403") || strings.Contains(errorOutput, "Forbidden") {
 
-				args = append(args, "--sleep-requests", "1", "--sleep-interval", "5", "--max-sleep-interval", "10")
-				args = append(args, "-o", "assets/video.%(ext)s", url)
+			// the retry's output replaces the failed attempt's, errors and file name included
+			outBuf.Reset()
+			errBuf.Reset()
+			args = videoArgs(job, "best", ffmpegPath, useFfmpeg, output)
 
-				cmd = exec.Command(path, args...)
-				cmd.Stdout = io.MultiWriter(&outBuf, logFile)
-				cmd.Stderr = io.MultiWriter(&errBuf, logFile)
-				err = cmd.Run()
+			cmd = job.command(path, args...)
+			cmd.Stdout = io.MultiWriter(&outBuf, logFile, job.progressWriter())
+			cmd.Stderr = io.MultiWriter(&errBuf, logFile)
+			err = cmd.Run()
 
-				if err != nil {
-					return VideoDownloadMsg{Error: fmt.Sprintf("Error downloading video: %v. Check output.log for details.", err)}
-				}
-			} else {
-				return VideoDownloadMsg{Error: fmt.Sprintf("Error downloading video: %v. Check output.log for details.", err)}
+			if err != nil {
+				return ytdlpError("downloading video", err, errBuf.String())
 			}
+		} else {
+			return ytdlpError("downloading video", err, errBuf.String())
 		}
+	}
 
-		return VideoDownloadMsg{Done: true}
+	if file := lastLine(outBuf.String()); file != "" {
+		job.reportFiles([]string{file})
 	}
+	return nil
+}
+
+// videoArgs are the yt-dlp arguments of a video job, for the format picked as well as the
+// fallback format retried after a 403
+func videoArgs(job *Job, formatID string, ffmpegPath string, useFfmpeg bool, output string) []string {
+	args := []string{"-f", formatID}
+
+	if useFfmpeg {
+		args = append(args, "--ffmpeg-location", ffmpegPath)
+	}
+
+	if job.Clip != nil {
+		args = append(args, "--download-sections", job.Clip.Section())
+		if useFfmpeg {
+			args = append(args, "--force-keyframes-at-cuts")
+		}
+	}
+
+	args = append(args, sponsorBlockArgs(job)...)
+	args = append(args, rateLimitArgs(job.rate)...)
+	args = append(args, "--sleep-requests", "1", "--sleep-interval", "5", "--max-sleep-interval", "10", "--newline")
+	args = append(args, titleArgs...)
+	args = append(args, "--print", "after_move:filepath", "--no-quiet")
+	return append(args, "-o", output, job.URL)
 }
 
 type VideoFormatMsg struct {
@@ -281,6 +298,8 @@ type VideoFormatMsg struct {
 }
 
 type VideoDownloadMsg struct {
+	JobID int
 	Done  bool
 	Error string
+	Files []string
 }
@@ -2,767 +2,1442 @@ package app
 
 import (
 	"fmt"
-	"os"
+	"path/filepath"
 	"strings"
-	"time"
 
+	"github.com/AbdelilahOu/Bubly-cli-app/config"
+	"github.com/AbdelilahOu/Bubly-cli-app/utils"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
-var YoutubeOptions = []ViewsOptions{
+var MenuOptions = []ViewsOptions{
 	{
-		View:        "yt-download-video",
-		ChoiceLabel: "Download Youtube video 📥",
+		View:        "download-video",
+		ChoiceLabel: "Download video 📥",
 	},
 	{
-		View:        "yt-download-audio",
-		ChoiceLabel: "Download Youtube audio 🎵",
+		View:        "download-audio",
+		ChoiceLabel: "Download audio 🎵",
 	},
 	{
-		View:        "yt-download-subtitles",
-		ChoiceLabel: "Download Youtube subtitles 📝",
+		View:        "download-subtitles",
+		ChoiceLabel: "Download subtitles 📝",
+	},
+	{
+		View:        "download-thumbnail",
+		ChoiceLabel: "Download thumbnail 🖼️",
+	},
+	{
+		View:        "chapters",
+		ChoiceLabel: "Browse chapters 📑",
+	},
+	{
+		View:        "search",
+		ChoiceLabel: "Search YouTube 🔎",
+	},
+	{
+		View:        "queue",
+		ChoiceLabel: "Download queue 📋",
+	},
+	{
+		View:        "subscriptions",
+		ChoiceLabel: "Channel & playlist subscriptions 🔁",
+	},
+	{
+		View:        "settings",
+		ChoiceLabel: "Settings ⚙️",
 	},
 }
 
-func UpdateYoutube(msg tea.Msg, m AppModel) (tea.Model, tea.Cmd) {
+// viewNouns name what the menu entries a site may not have are about
+var viewNouns = map[string]string{
+	"download-video":     "video",
+	"download-subtitles": "subtitles",
+	"chapters":           "chapters",
+}
 
-	if len(m.History) > 0 && m.History[0] == "yt-download-audio" && m.IsUrlWritten && m.AudioFormatSel != nil {
+type menuScreen struct {
+	layout *Layout
+	choice int
+}
+
+func NewMenuScreen(layout *Layout) Screen {
+	return &menuScreen{layout: layout}
+}
+
+func (s *menuScreen) Init() tea.Cmd {
+	return nil
+}
+
+func (s *menuScreen) Title() string {
+	return "Video tools 🔨"
+}
+
+func (s *menuScreen) Keys() []key.Binding {
+	return []key.Binding{keys.Up, keys.Down, keys.Select}
+}
 
-		debugFile, _ := os.OpenFile("debug.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		if debugFile != nil {
-			fmt.Fprintf(debugFile, "Handling audio format selection, IsUrlWritten: %t, AudioFormatSel != nil: %t\n", m.IsUrlWritten, m.AudioFormatSel != nil)
-			if m.AudioFormatSel != nil {
-				fmt.Fprintf(debugFile, "AudioFormatSel: Formats=%d, Choice=%d, Selected=%t\n", len(m.AudioFormatSel.Formats), m.AudioFormatSel.Choice, m.AudioFormatSel.Selected)
+func (s *menuScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(msg, keys.Down):
+			if len(MenuOptions) > s.choice+1 {
+				s.choice++
 			}
-			debugFile.Close()
+		case key.Matches(msg, keys.Up):
+			if s.choice > 0 {
+				s.choice--
+			}
+		case key.Matches(msg, keys.Select):
+			return s, pushScreen(s.open(MenuOptions[s.choice]))
 		}
+	}
+	return s, nil
+}
 
-		switch msg := msg.(type) {
-		case tea.KeyMsg:
-			switch msg.String() {
-			case "j", "down":
-				if len(m.AudioFormatSel.Formats) > m.AudioFormatSel.Choice+1 {
-					m.AudioFormatSel.Choice++
+func (s *menuScreen) open(option ViewsOptions) Screen {
+	switch option.View {
+	case "search":
+		return newSearchScreen(s.layout)
+	case "queue":
+		return newQueueScreen(s.layout)
+	case "subscriptions":
+		return newSubscriptionsScreen(s.layout)
+	case "settings":
+		return newSettingsScreen(s.layout)
+	}
+	return newURLScreen(s.layout, option.ChoiceLabel, option.View, urlTool(s.layout, option.View))
+}
 
-					itemsPerPage := m.ItemsPerPage
-					newPage := m.AudioFormatSel.Choice / itemsPerPage
-					if newPage != m.Page {
-						m.Page = newPage
-					}
-				}
-				return m, nil
-			case "k", "up":
-				if m.AudioFormatSel.Choice > 0 {
-					m.AudioFormatSel.Choice--
-
-					itemsPerPage := m.ItemsPerPage
-					newPage := m.AudioFormatSel.Choice / itemsPerPage
-					if newPage != m.Page {
-						m.Page = newPage
-					}
-				}
-				return m, nil
-			case "h", "left":
+// urlTool is the screen a menu entry opens once it has a URL, nil for the entries that don't
+// take one
+func urlTool(layout *Layout, view string) func(url string) Screen {
+	switch view {
+	case "download-video":
+		return func(url string) Screen { return newVideoScreen(layout, url) }
+	case "download-audio":
+		return func(url string) Screen { return newAudioScreen(layout, url) }
+	case "download-subtitles":
+		return func(url string) Screen { return newSubtitlesScreen(layout, url) }
+	case "download-thumbnail":
+		return func(url string) Screen { return newThumbnailScreen(layout, url) }
+	case "chapters":
+		return func(url string) Screen { return newChaptersScreen(layout, url) }
+	}
+	return nil
+}
 
-				if m.Page > 0 {
-					m.Page--
+func (s *menuScreen) View() string {
+	var b strings.Builder
+	b.WriteString("What do you wanna do?\n\n")
+	b.WriteString(fmt.Sprintf(
+		strings.Repeat("%s\n", len(MenuOptions)),
+		destructureOptions(MenuOptions, s.choice)...,
+	))
+	return b.String()
+}
 
-					itemsPerPage := m.ItemsPerPage
-					m.AudioFormatSel.Choice = m.Page * itemsPerPage
-				}
-				return m, nil
-			case "l", "right":
+// urlScreen asks for a video URL and opens the next screen with it. The URL stays in the
+// input, so going back to fix a typo doesn't mean pasting it again. The site of the URL is
+// shown as it's typed, view is the menu entry it's for, which not every site has.
+type urlScreen struct {
+	layout   *Layout
+	title    string
+	view     string
+	textarea textarea.Model
+	err      string
+	next     func(url string) Screen
+}
 
-				totalItems := len(m.AudioFormatSel.Formats)
-				itemsPerPage := m.ItemsPerPage
-				totalPages := (totalItems + itemsPerPage - 1) / itemsPerPage
-				if m.Page < totalPages-1 {
-					m.Page++
+func newURLScreen(layout *Layout, title string, view string, next func(url string) Screen) *urlScreen {
+	ta := textarea.New()
+	ta.Placeholder = "Pass in a url..."
+	ta.Focus()
 
-					m.AudioFormatSel.Choice = m.Page * itemsPerPage
-				}
-				return m, nil
-			case "enter":
-				if !m.AudioFormatSel.Selected {
-					m.AudioFormatSel.Selected = true
-					m.AudioFormatSel.Downloading = true
-					formatID := m.AudioFormatSel.Formats[m.AudioFormatSel.Choice].ID
-					return m, m.downloadAudio(m.AudioFormatSel.URL, formatID)
-				}
-				return m, nil
-			}
-		case AudioDownloadMsg:
-			m.AudioFormatSel.Downloading = false
-			if msg.Error != "" {
-				m.AudioFormatSel.Error = true
-				m.AudioFormatSel.ErrMsg = msg.Error
-			} else {
-				m.AudioFormatSel.Done = true
-			}
-			return m, nil
-		}
-		return m, nil
-	}
-
-	if len(m.History) > 0 && m.History[0] == "yt-download-video" && m.IsUrlWritten && m.VideoFormatSel != nil {
-		switch msg := msg.(type) {
-		case tea.KeyMsg:
-			switch msg.String() {
-			case "j", "down":
-				if len(m.VideoFormatSel.Formats) > m.VideoFormatSel.Choice+1 {
-					m.VideoFormatSel.Choice++
-
-					itemsPerPage := m.ItemsPerPage
-					newPage := m.VideoFormatSel.Choice / itemsPerPage
-					if newPage != m.Page {
-						m.Page = newPage
-					}
-				}
-				return m, nil
-			case "k", "up":
-				if m.VideoFormatSel.Choice > 0 {
-					m.VideoFormatSel.Choice--
-
-					itemsPerPage := m.ItemsPerPage
-					newPage := m.VideoFormatSel.Choice / itemsPerPage
-					if newPage != m.Page {
-						m.Page = newPage
-					}
-				}
-				return m, nil
-			case "h", "left":
+	ta.Prompt = "┃ "
+	ta.CharLimit = 280
 
-				if m.Page > 0 {
-					m.Page--
+	ta.SetWidth(inputWidth(layout))
+	ta.SetHeight(2)
 
-					itemsPerPage := m.ItemsPerPage
-					m.VideoFormatSel.Choice = m.Page * itemsPerPage
-				}
-				return m, nil
-			case "l", "right":
+	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
 
-				totalItems := len(m.VideoFormatSel.Formats)
-				itemsPerPage := m.ItemsPerPage
-				totalPages := (totalItems + itemsPerPage - 1) / itemsPerPage
-				if m.Page < totalPages-1 {
-					m.Page++
+	ta.ShowLineNumbers = false
 
-					m.VideoFormatSel.Choice = m.Page * itemsPerPage
-				}
-				return m, nil
-			case "enter":
-				if !m.VideoFormatSel.Selected {
-					m.VideoFormatSel.Selected = true
-					m.VideoFormatSel.Downloading = true
-					formatID := m.VideoFormatSel.Formats[m.VideoFormatSel.Choice].ID
-					return m, m.downloadVideo(m.VideoFormatSel.URL, formatID)
-				}
-				return m, nil
-			}
-		case VideoDownloadMsg:
-			m.VideoFormatSel.Downloading = false
-			if msg.Error != "" {
-				m.VideoFormatSel.Error = true
-				m.VideoFormatSel.ErrMsg = msg.Error
-			} else {
-				m.VideoFormatSel.Done = true
-			}
-			return m, nil
-		}
-		return m, nil
-	}
-
-	if len(m.History) > 0 && m.History[0] == "yt-download-subtitles" && m.IsUrlWritten && m.SubtitleSel != nil {
-		switch msg := msg.(type) {
-		case tea.KeyMsg:
-			switch msg.String() {
-			case "j", "down":
-				if len(m.SubtitleSel.Languages) > m.SubtitleSel.Choice+1 {
-					m.SubtitleSel.Choice++
-
-					itemsPerPage := m.ItemsPerPage
-					newPage := m.SubtitleSel.Choice / itemsPerPage
-					if newPage != m.Page {
-						m.Page = newPage
-					}
-				}
-				return m, nil
-			case "k", "up":
-				if m.SubtitleSel.Choice > 0 {
-					m.SubtitleSel.Choice--
-
-					itemsPerPage := m.ItemsPerPage
-					newPage := m.SubtitleSel.Choice / itemsPerPage
-					if newPage != m.Page {
-						m.Page = newPage
-					}
-				}
-				return m, nil
-			case "h", "left":
+	ta.KeyMap.InsertNewline.SetEnabled(false)
 
-				if m.Page > 0 {
-					m.Page--
+	return &urlScreen{layout: layout, title: title, view: view, textarea: ta, next: next}
+}
 
-					itemsPerPage := m.ItemsPerPage
-					m.SubtitleSel.Choice = m.Page * itemsPerPage
-				}
-				return m, nil
-			case "l", "right":
+func (s *urlScreen) Init() tea.Cmd {
+	return tea.Batch(textarea.Blink, loadExtractors())
+}
 
-				totalItems := len(m.SubtitleSel.Languages)
-				itemsPerPage := m.ItemsPerPage
-				totalPages := (totalItems + itemsPerPage - 1) / itemsPerPage
-				if m.Page < totalPages-1 {
-					m.Page++
+func (s *urlScreen) Title() string {
+	return s.title
+}
 
-					m.SubtitleSel.Choice = m.Page * itemsPerPage
-				}
-				return m, nil
-			case "enter":
-				if !m.SubtitleSel.Selected {
-					m.SubtitleSel.Selected = true
-					m.SubtitleSel.Downloading = true
-					langCode := m.SubtitleSel.Languages[m.SubtitleSel.Choice].Code
-					return m, m.downloadSubtitles(m.SubtitleSel.URL, langCode)
-				}
-				return m, nil
+func (s *urlScreen) Keys() []key.Binding {
+	return []key.Binding{withDesc(keys.Select, "open"), withDesc(keys.Back, "back when empty")}
+}
+
+func (s *urlScreen) Typing() bool {
+	return true
+}
+
+func (s *urlScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
+	if _, ok := msg.(tea.WindowSizeMsg); ok {
+		s.textarea.SetWidth(inputWidth(s.layout))
+		return s, nil
+	}
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case typedKey(msg):
+		case key.Matches(msg, keys.Select):
+			url, err := s.parse()
+			if err != nil {
+				s.err = err.Error()
+				return s, nil
 			}
-		case SubtitleDownloadMsg:
-			m.SubtitleSel.Downloading = false
-			if msg.Error != "" {
-				m.SubtitleSel.Error = true
-				m.SubtitleSel.ErrMsg = msg.Error
-			} else {
-				m.SubtitleSel.Done = true
+			s.err = ""
+			return s, pushScreen(s.next(url))
+		case key.Matches(msg, keys.Back):
+			if s.textarea.Value() == "" {
+				return s, popScreen()
 			}
-			return m, nil
 		}
-		return m, nil
 	}
 
-	if len(m.History) > 0 {
-		switch m.History[0] {
-		case "yt-download-video":
-			return UpdateDownloadVideo(msg, m)
-		case "yt-download-audio":
-			return UpdateDownloadAudio(msg, m)
-		case "yt-download-subtitles":
-			return UpdateDownloadSubtitles(msg, m)
+	var cmd tea.Cmd
+	s.textarea, cmd = s.textarea.Update(msg)
+
+	// once a submission failed, the error follows the input until it parses
+	if s.err != "" {
+		if _, err := s.parse(); err != nil {
+			s.err = err.Error()
+		} else {
+			s.err = ""
 		}
 	}
+	return s, cmd
+}
+
+// parse checks the URL typed and that its site has what the screen is for
+func (s *urlScreen) parse() (string, error) {
+	parsed, err := ParseVideoURL(s.textarea.Value())
+	if err != nil {
+		return "", err
+	}
+	url := parsed.String()
+	if site := DetectSite(url); !site.Supports(s.view) {
+		return "", fmt.Errorf("%s has no %s, pick another tool from the menu", site.Label, viewNouns[s.view])
+	}
+	return url, nil
+}
+
+func (s *urlScreen) View() string {
+	if s.err != "" {
+		return s.textarea.View() + "\n" + urlErrorStyle(s.layout.Truncate("✗ "+s.err))
+	}
+	parsed, err := ParseVideoURL(s.textarea.Value())
+	if err != nil {
+		return s.textarea.View()
+	}
+	site := DetectSite(parsed.String())
+	if site.Generic {
+		return s.textarea.View() + "\n" + infoLabelStyle(s.layout.Truncate("No yt-dlp extractor knows "+site.Label+", its generic one will try"))
+	}
+	return s.textarea.View() + "\n" + infoLabelStyle("Site:") + " " + site.Label
+}
+
+type audioScreen struct {
+	layout *Layout
+	url    string
+	info   *VideoInfo
+	clip   *TimeRange
+	sel    *AudioFormatSelection
+	// sponsor is nil for sites SponsorBlock doesn't cover
+	sponsor *sponsorBlockView
+	// fetched is set once the info came in or failed, formatErr waits for it
+	fetched   bool
+	formatErr string
+}
+
+func newAudioScreen(layout *Layout, url string) *audioScreen {
+	s := &audioScreen{layout: layout, url: url, sponsor: newSponsorBlockView(url)}
+	if start, ok := StartFromURL(url); ok {
+		s.clip = &TimeRange{Start: start}
+	}
+	return s
+}
+
+func (s *audioScreen) Init() tea.Cmd {
+	return tea.Batch(fetchAudioFormats(s.url), fetchVideoInfo(s.url), fetchSponsorSegments(s.url))
+}
+
+func (s *audioScreen) Title() string {
+	return "Audio formats"
+}
+
+func (s *audioScreen) Keys() []key.Binding {
+	if s.info != nil && s.info.IsLive() {
+		return []key.Binding{withDesc(keys.Select, "record")}
+	}
+	if s.sel == nil || s.sel.Selected || len(s.sel.Formats) == 0 {
+		return nil
+	}
+	bindings := append(listKeys(len(s.sel.Formats), s.layout.ItemsPerPage), withDesc(keys.Select, "download"), keys.Clip)
+	if s.clip != nil {
+		bindings = append(bindings, keys.ClearClip)
+	}
+	if s.sponsor != nil {
+		bindings = append(bindings, keys.Sponsors)
+	}
+	return bindings
+}
+
+func (s *audioScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
 	switch msg := msg.(type) {
-	case tea.KeyMsg:
-		switch msg.String() {
-		case "j", "down":
-			if len(YoutubeOptions) > m.Choice+1 {
-				m.Choice++
-			}
-		case "k", "up":
-			if m.Choice > 0 {
-				m.Choice--
+	case SponsorSegmentsMsg:
+		if msg.URL == s.url && s.sponsor != nil {
+			s.sponsor.update(msg)
+		}
+	case VideoInfoMsg:
+		if msg.URL != s.url || s.fetched {
+			return s, nil
+		}
+		s.fetched = true
+		if msg.Info == nil {
+			if s.formatErr != "" {
+				return s, popWithWarning(s.formatErr)
 			}
-		case "enter":
-			m.IsTextAreaActive = true
-			m = appendToHistory(m, YoutubeOptions[m.Choice].View)
-			return m, nil
+			return s, nil
 		}
+		s.info = msg.Info
+		if msg.Info.IsLive() {
+			return s, pushScreen(newRecordScreen(s.layout, s.url, msg.Info, JobAudio))
+		}
+		if s.formatErr != "" {
+			return s, popWithWarning(s.formatErr)
+		}
+		var cmd tea.Cmd
+		s.clip, cmd = checkClip(s.clip, msg.Info)
+		return s, cmd
 	case AudioFormatMsg:
-
-		debugFile, _ := os.OpenFile("debug.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		if debugFile != nil {
-			fmt.Fprintf(debugFile, "Main UpdateYoutube received AudioFormatMsg, Error: %s, Formats count: %d\n", msg.Error, len(msg.Formats))
-			debugFile.Close()
+		if msg.URL != s.url {
+			return s, nil
 		}
-
 		if msg.Error != "" {
-			m.Warning = msg.Error
-			m.IsUrlWritten = false
-			m.PrintingError = true
-		} else {
-			m.AudioFormatSel = &AudioFormatSelection{
-				URL:     msg.URL,
-				Formats: msg.Formats,
-				Choice:  0,
+			// streams that haven't started have no formats, the info tells whether that's it
+			if !s.fetched {
+				s.formatErr = msg.Error
+				return s, nil
 			}
-
-			m.Page = 0
+			if s.info != nil && s.info.IsLive() {
+				return s, nil
+			}
+			return s, popWithWarning(msg.Error)
 		}
-		return m, nil
-	case VideoFormatMsg:
+		s.sel = &AudioFormatSelection{
+			URL:     msg.URL,
+			Formats: msg.Formats,
+		}
+	case ClipRangeMsg:
+		s.clip = msg.Clip
+	case AudioDownloadMsg:
+		if s.sel == nil || !s.sel.Downloading || msg.JobID != s.sel.JobID {
+			return s, nil
+		}
+		s.sel.Downloading = false
 		if msg.Error != "" {
-			m.Warning = msg.Error
-			m.IsUrlWritten = false
-			m.PrintingError = true
+			s.sel.Error = true
+			s.sel.ErrMsg = msg.Error
 		} else {
-			m.VideoFormatSel = &VideoFormatSelection{
-				URL:     msg.URL,
-				Formats: msg.Formats,
-				Choice:  0,
+			s.sel.Done = true
+			return s, showFiles(s.layout, "Audio downloaded", msg.Files, clipDuration(s.clip, s.info))
+		}
+	case tea.KeyMsg:
+		if s.info != nil && s.info.IsLive() {
+			if key.Matches(msg, keys.Select) {
+				return s, pushScreen(newRecordScreen(s.layout, s.url, s.info, JobAudio))
 			}
+			return s, nil
+		}
+		if s.sel == nil || s.sel.Selected || len(s.sel.Formats) == 0 {
+			return s, nil
+		}
+		if movePage(msg, &s.sel.Choice, len(s.sel.Formats), s.layout.ItemsPerPage) {
+			return s, nil
+		}
+		switch {
+		case key.Matches(msg, keys.Clip):
+			return s, pushScreen(newClipScreen(s.layout, s.url, s.clip, s.info))
+		case key.Matches(msg, keys.ClearClip):
+			s.clip = nil
+		case key.Matches(msg, keys.Sponsors) && s.sponsor != nil:
+			s.sponsor.mode = nextSponsorBlockMode(s.sponsor.mode)
+		case key.Matches(msg, keys.Select):
+			format := s.sel.Formats[s.sel.Choice]
+			job := &Job{Kind: JobAudio, URL: s.sel.URL, Format: format.ID, Clip: s.clip, Size: estimateSize(format.Filesize, s.clip, s.info)}
+			if s.sponsor != nil {
+				job.SponsorBlock = s.sponsor.mode
+			}
+			// checked here too so a format that doesn't fit can be swapped for a smaller one
+			warning, err := SharedQueue().CheckSpace(job)
+			if err != nil {
+				return s, warn(err.Error())
+			}
+			s.sel.Selected = true
+			s.sel.Downloading = true
+			s.sel.JobID = SharedQueue().Add(job).ID
+			return s, tea.Batch(downloadAudio(s.sel.JobID), warn(warning))
+		}
+	}
+	return s, nil
+}
+
+func (s *audioScreen) View() string {
+	var b strings.Builder
+	b.WriteString(infoHeader(s.layout, s.info))
+
+	if s.info != nil && s.info.IsLive() {
+		b.WriteString(s.layout.Wrap(s.info.StatusLabel() + ", it can be recorded rather than downloaded"))
+		return b.String()
+	}
+	if s.sel == nil {
+		b.WriteString("Fetching available audio formats for: " + s.url + "\n")
+		return b.String()
+	}
 
-			m.Page = 0
+	if s.sel.Error {
+		b.WriteString(ErrorStyle(s.layout.Wrap("Error: " + s.sel.ErrMsg)))
+	} else if s.sel.Done {
+		b.WriteString(SuccessStyle("Audio downloaded successfully! Check the " + outputPath() + " folder"))
+	} else if job, _ := SharedQueue().Get(s.sel.JobID); s.sel.Downloading && !job.ScheduledFor.IsZero() {
+		b.WriteString("⏰ Scheduled for " + scheduleLabel(job.ScheduledFor) + "\n")
+		b.WriteString(s.layout.Wrap("Downloads start between " + strings.Join(config.Current().Schedule, ", ") + ", it's in the queue until then"))
+	} else if s.sel.Downloading {
+		b.WriteString("🔊 Downloading audio " + spinnerFrame() + "\n")
+		b.WriteString("This may take a few moments...")
+	} else if len(s.sel.Formats) > 0 {
+		if s.clip != nil {
+			b.WriteString("✂️ Clip: " + s.clip.String() + "\n\n")
 		}
-		return m, nil
-	case SubtitleLangMsg:
-		if msg.Error != "" {
-			m.Warning = msg.Error
-			m.IsUrlWritten = false
-			m.PrintingError = true
-		} else {
-			m.SubtitleSel = &SubtitleSelection{
-				URL:       msg.URL,
-				Languages: msg.Languages,
-				Choice:    0,
+		if s.sponsor != nil {
+			b.WriteString(s.sponsor.View(s.layout))
+		}
+		b.WriteString("Select audio format:\n\n")
+
+		start, end := pageBounds(s.sel.Choice, len(s.sel.Formats), s.layout.ItemsPerPage)
+		for i := start; i < end; i++ {
+			format := s.sel.Formats[i]
+			cursor := "  "
+			if s.sel.Choice == i {
+				cursor = "> "
 			}
 
-			m.Page = 0
+			line := fmt.Sprintf("%s%s %s %s",
+				cursor,
+				audioQualityStyle(format.Quality),
+				audioFormatStyle(format.Format),
+				audioFileSizeStyle(format.Filesize))
+
+			b.WriteString(s.layout.Truncate(line) + "\n")
+		}
+		b.WriteString(pageFooter(s.sel.Choice, len(s.sel.Formats), s.layout.ItemsPerPage))
+	} else {
+		b.WriteString(WarningStyle("No audio formats found for this video"))
+	}
+	return b.String()
+}
+
+type videoScreen struct {
+	layout *Layout
+	url    string
+	info   *VideoInfo
+	clip   *TimeRange
+	sel    *VideoFormatSelection
+	// sponsor is nil for sites SponsorBlock doesn't cover
+	sponsor *sponsorBlockView
+	// fetched is set once the info came in or failed, formatErr waits for it
+	fetched   bool
+	formatErr string
+}
+
+func newVideoScreen(layout *Layout, url string) *videoScreen {
+	s := &videoScreen{layout: layout, url: url, sponsor: newSponsorBlockView(url)}
+	if start, ok := StartFromURL(url); ok {
+		s.clip = &TimeRange{Start: start}
+	}
+	return s
+}
+
+func (s *videoScreen) Init() tea.Cmd {
+	return tea.Batch(fetchVideoFormats(s.url), fetchVideoInfo(s.url), fetchSponsorSegments(s.url))
+}
+
+func (s *videoScreen) Title() string {
+	return "Video formats"
+}
+
+func (s *videoScreen) Keys() []key.Binding {
+	if s.info != nil && s.info.IsLive() {
+		return []key.Binding{withDesc(keys.Select, "record")}
+	}
+	if s.sel == nil || s.sel.Selected || len(s.sel.Formats) == 0 {
+		return nil
+	}
+	bindings := append(listKeys(len(s.sel.Formats), s.layout.ItemsPerPage), withDesc(keys.Select, "download"), keys.Clip)
+	if s.clip != nil {
+		bindings = append(bindings, keys.ClearClip)
+	}
+	if s.sponsor != nil {
+		bindings = append(bindings, keys.Sponsors)
+	}
+	return bindings
+}
+
+func (s *videoScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
+	switch msg := msg.(type) {
+	case SponsorSegmentsMsg:
+		if msg.URL == s.url && s.sponsor != nil {
+			s.sponsor.update(msg)
+		}
+	case VideoInfoMsg:
+		if msg.URL != s.url || s.fetched {
+			return s, nil
+		}
+		s.fetched = true
+		if msg.Info == nil {
+			if s.formatErr != "" {
+				return s, popWithWarning(s.formatErr)
+			}
+			return s, nil
+		}
+		s.info = msg.Info
+		if msg.Info.IsLive() {
+			return s, pushScreen(newRecordScreen(s.layout, s.url, msg.Info, JobVideo))
+		}
+		if s.formatErr != "" {
+			return s, popWithWarning(s.formatErr)
+		}
+		var cmd tea.Cmd
+		s.clip, cmd = checkClip(s.clip, msg.Info)
+		return s, cmd
+	case VideoFormatMsg:
+		if msg.URL != s.url {
+			return s, nil
 		}
-		return m, nil
-	case AudioDownloadMsg:
 		if msg.Error != "" {
-			m.PrintingError = true
-			m.Warning = msg.Error
-		} else {
-			m.PrintingIsDone = true
+			// streams that haven't started have no formats, the info tells whether that's it
+			if !s.fetched {
+				s.formatErr = msg.Error
+				return s, nil
+			}
+			if s.info != nil && s.info.IsLive() {
+				return s, nil
+			}
+			return s, popWithWarning(msg.Error)
+		}
+		s.sel = &VideoFormatSelection{
+			URL:     msg.URL,
+			Formats: msg.Formats,
 		}
-		return m, nil
+	case ClipRangeMsg:
+		s.clip = msg.Clip
 	case VideoDownloadMsg:
-		if msg.Error != "" {
-			m.PrintingError = true
-			m.Warning = msg.Error
-		} else {
-			m.PrintingIsDone = true
+		if s.sel == nil || !s.sel.Downloading || msg.JobID != s.sel.JobID {
+			return s, nil
 		}
-		return m, nil
-	case SubtitleDownloadMsg:
+		s.sel.Downloading = false
 		if msg.Error != "" {
-			m.PrintingError = true
-			m.Warning = msg.Error
+			s.sel.Error = true
+			s.sel.ErrMsg = msg.Error
 		} else {
-			m.PrintingIsDone = true
+			s.sel.Done = true
+			return s, showFiles(s.layout, "Video downloaded", msg.Files, clipDuration(s.clip, s.info))
+		}
+	case tea.KeyMsg:
+		if s.info != nil && s.info.IsLive() {
+			if key.Matches(msg, keys.Select) {
+				return s, pushScreen(newRecordScreen(s.layout, s.url, s.info, JobVideo))
+			}
+			return s, nil
+		}
+		if s.sel == nil || s.sel.Selected || len(s.sel.Formats) == 0 {
+			return s, nil
+		}
+		if movePage(msg, &s.sel.Choice, len(s.sel.Formats), s.layout.ItemsPerPage) {
+			return s, nil
+		}
+		switch {
+		case key.Matches(msg, keys.Clip):
+			return s, pushScreen(newClipScreen(s.layout, s.url, s.clip, s.info))
+		case key.Matches(msg, keys.ClearClip):
+			s.clip = nil
+		case key.Matches(msg, keys.Sponsors) && s.sponsor != nil:
+			s.sponsor.mode = nextSponsorBlockMode(s.sponsor.mode)
+		case key.Matches(msg, keys.Select):
+			format := s.sel.Formats[s.sel.Choice]
+			job := &Job{Kind: JobVideo, URL: s.sel.URL, Format: format.ID, Clip: s.clip, Size: estimateSize(format.Filesize, s.clip, s.info)}
+			if s.sponsor != nil {
+				job.SponsorBlock = s.sponsor.mode
+			}
+			// checked here too so a format that doesn't fit can be swapped for a smaller one
+			warning, err := SharedQueue().CheckSpace(job)
+			if err != nil {
+				return s, warn(err.Error())
+			}
+			s.sel.Selected = true
+			s.sel.Downloading = true
+			s.sel.JobID = SharedQueue().Add(job).ID
+			return s, tea.Batch(downloadVideo(s.sel.JobID), warn(warning))
 		}
-		return m, nil
-
 	}
-	return m, nil
+	return s, nil
 }
 
-func YoutubeView(m AppModel) string {
-	c := m.Choice
-	var s strings.Builder
-
-	if len(m.History) > 0 {
-		switch m.History[0] {
-		case "yt-download-video":
-			s.WriteString(DownloadVideoView(m))
-		case "yt-download-audio":
-			s.WriteString(DownloadAudioView(m))
-		case "yt-download-subtitles":
-			s.WriteString(DownloadSubtitlesView(m))
-		}
-		s.WriteString("\n\n")
-	} else {
-		s.WriteString(TitleStyle("What youtube tools do you wanna use? 🔨"))
-		s.WriteString("\n\n")
+func (s *videoScreen) View() string {
+	var b strings.Builder
+	b.WriteString(infoHeader(s.layout, s.info))
 
-		choices := fmt.Sprintf(
-			strings.Repeat("%s\n", len(YoutubeOptions)),
-			destructureOptions(YoutubeOptions, c)...,
-		)
-		s.WriteString(choices)
-		s.WriteString("\n")
+	if s.info != nil && s.info.IsLive() {
+		b.WriteString(s.layout.Wrap(s.info.StatusLabel() + ", it can be recorded rather than downloaded"))
+		return b.String()
+	}
+	if s.sel == nil {
+		b.WriteString("Fetching available video formats for: " + s.url + "\n")
+		return b.String()
 	}
 
-	return s.String()
-}
+	if s.sel.Error {
+		b.WriteString(ErrorStyle(s.layout.Wrap("Error: " + s.sel.ErrMsg)))
+	} else if s.sel.Done {
+		b.WriteString(SuccessStyle("Video downloaded successfully! Check the " + outputPath() + " folder"))
+	} else if job, _ := SharedQueue().Get(s.sel.JobID); s.sel.Downloading && !job.ScheduledFor.IsZero() {
+		b.WriteString("⏰ Scheduled for " + scheduleLabel(job.ScheduledFor) + "\n")
+		b.WriteString(s.layout.Wrap("Downloads start between " + strings.Join(config.Current().Schedule, ", ") + ", it's in the queue until then"))
+	} else if s.sel.Downloading {
+		b.WriteString("📥 Downloading video " + spinnerFrame() + "\n")
+		b.WriteString("This may take a few moments...")
+	} else if len(s.sel.Formats) > 0 {
+		if s.clip != nil {
+			b.WriteString("✂️ Clip: " + s.clip.String() + "\n\n")
+		}
+		if s.sponsor != nil {
+			b.WriteString(s.sponsor.View(s.layout))
+		}
+		b.WriteString("Select video format:\n\n")
+
+		start, end := pageBounds(s.sel.Choice, len(s.sel.Formats), s.layout.ItemsPerPage)
+		for i := start; i < end; i++ {
+			format := s.sel.Formats[i]
+			cursor := "  "
+			if s.sel.Choice == i {
+				cursor = "> "
+			}
 
-func DownloadVideoView(m AppModel) string {
-	var s strings.Builder
-	s.WriteString(TitleStyle("Download Youtube video 📥"))
-	s.WriteString("\n\n")
+			line := fmt.Sprintf("%s%s %s %s %s",
+				cursor,
+				videoQualityStyle(format.Quality),
+				videoFormatStyle(format.Format),
+				videoResolutionStyle(format.Resolution),
+				videoFileSizeStyle(format.Filesize))
 
-	if m.IsUrlWritten {
+			b.WriteString(s.layout.Truncate(line) + "\n")
+		}
+		b.WriteString(pageFooter(s.sel.Choice, len(s.sel.Formats), s.layout.ItemsPerPage))
+	} else {
+		b.WriteString(WarningStyle("No video formats found for this video"))
+	}
+	return b.String()
+}
 
-		if m.VideoFormatSel != nil {
-			if m.VideoFormatSel.Error {
-				s.WriteString(ErrorStyle("Error: " + m.VideoFormatSel.ErrMsg))
-			} else if m.VideoFormatSel.Done {
-				s.WriteString(SuccessStyle("Video downloaded successfully! Check assets folder"))
-			} else if m.VideoFormatSel.Downloading {
+type subtitlesScreen struct {
+	layout *Layout
+	url    string
+	info   *VideoInfo
+	sel    *SubtitleSelection
+}
 
-				s.WriteString("📥 Downloading video")
+func newSubtitlesScreen(layout *Layout, url string) *subtitlesScreen {
+	return &subtitlesScreen{layout: layout, url: url}
+}
 
-				spinner := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
-				frame := time.Now().UnixNano() / 100000000 % int64(len(spinner))
-				s.WriteString(" " + spinner[frame] + "\n")
-				s.WriteString("This may take a few moments...")
-			} else if len(m.VideoFormatSel.Formats) > 0 {
-				s.WriteString("Select video format:\n\n")
+func (s *subtitlesScreen) Init() tea.Cmd {
+	return tea.Batch(fetchSubtitleLanguages(s.url), fetchVideoInfo(s.url))
+}
 
-				totalItems := len(m.VideoFormatSel.Formats)
-				itemsPerPage := m.ItemsPerPage
-				totalPages := (totalItems + itemsPerPage - 1) / itemsPerPage
-				currentPage := m.Page
+func (s *subtitlesScreen) Title() string {
+	return "Languages"
+}
 
-				if currentPage >= totalPages {
-					currentPage = totalPages - 1
-				}
-				if currentPage < 0 {
-					currentPage = 0
-				}
+func (s *subtitlesScreen) Keys() []key.Binding {
+	if s.sel == nil || s.sel.Selected || len(s.sel.Languages) == 0 {
+		return nil
+	}
+	return append(listKeys(len(s.sel.Languages), s.layout.ItemsPerPage), withDesc(keys.Select, "download"))
+}
 
-				startIdx := currentPage * itemsPerPage
-				endIdx := startIdx + itemsPerPage
-				if endIdx > totalItems {
-					endIdx = totalItems
-				}
+func (s *subtitlesScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
+	switch msg := msg.(type) {
+	case VideoInfoMsg:
+		if msg.URL == s.url && msg.Info != nil {
+			s.info = msg.Info
+		}
+	case SubtitleLangMsg:
+		if msg.URL != s.url {
+			return s, nil
+		}
+		if msg.Error != "" {
+			return s, popWithWarning(msg.Error)
+		}
+		s.sel = &SubtitleSelection{
+			URL:       msg.URL,
+			Languages: msg.Languages,
+		}
+	case SubtitleDownloadMsg:
+		if s.sel == nil || !s.sel.Downloading || msg.JobID != s.sel.JobID {
+			return s, nil
+		}
+		s.sel.Downloading = false
+		if msg.Error != "" {
+			s.sel.Error = true
+			s.sel.ErrMsg = msg.Error
+		} else {
+			s.sel.Done = true
+			return s, showFiles(s.layout, "Subtitles downloaded", msg.Files, 0)
+		}
+	case tea.KeyMsg:
+		if s.sel == nil || s.sel.Selected || len(s.sel.Languages) == 0 {
+			return s, nil
+		}
+		if movePage(msg, &s.sel.Choice, len(s.sel.Languages), s.layout.ItemsPerPage) {
+			return s, nil
+		}
+		if key.Matches(msg, keys.Select) {
+			s.sel.Selected = true
+			s.sel.Downloading = true
+			langCode := s.sel.Languages[s.sel.Choice].Code
+			s.sel.JobID = SharedQueue().Add(&Job{Kind: JobSubtitles, URL: s.sel.URL, Lang: langCode}).ID
+			return s, downloadSubtitles(s.sel.JobID)
+		}
+	}
+	return s, nil
+}
 
-				for i := startIdx; i < endIdx; i++ {
-					format := m.VideoFormatSel.Formats[i]
-					cursor := "  "
-					if m.VideoFormatSel.Choice == i {
-						cursor = "> "
-					}
-
-					line := fmt.Sprintf("%s%s %s %s %s",
-						cursor,
-						videoQualityStyle(format.Quality),
-						videoFormatStyle(format.Format),
-						videoResolutionStyle(format.Resolution),
-						videoFileSizeStyle(format.Filesize))
-
-					s.WriteString(line + "\n")
-				}
+func (s *subtitlesScreen) View() string {
+	var b strings.Builder
+	b.WriteString(infoHeader(s.layout, s.info))
 
-				if totalPages > 1 {
-					s.WriteString("\n")
-					s.WriteString(fmt.Sprintf("Page %d of %d | ", currentPage+1, totalPages))
-					if currentPage > 0 {
-						s.WriteString("<-- Previous (h) ")
-					}
-					if currentPage < totalPages-1 {
-						s.WriteString("Next (l) -->")
-					}
-				}
+	if s.sel == nil {
+		b.WriteString("Fetching available subtitle languages for: " + s.url + "\n")
+		return b.String()
+	}
 
-				s.WriteString("\n\n(Press ↑/↓ to select, Enter to download, h/l for pagination)")
-			} else {
-				s.WriteString("Loading available formats...")
+	if s.sel.Error {
+		b.WriteString(ErrorStyle(s.layout.Wrap("Error: " + s.sel.ErrMsg)))
+	} else if s.sel.Done {
+		b.WriteString(SuccessStyle("Subtitles downloaded successfully! Check the " + outputPath() + " folder"))
+	} else if s.sel.Downloading {
+		selectedLang := s.sel.Languages[s.sel.Choice].Name
+		b.WriteString("📝 Downloading " + selectedLang + " subtitles " + spinnerFrame() + "\n")
+		b.WriteString("This may take a few moments...")
+	} else if len(s.sel.Languages) > 0 {
+		b.WriteString("Select subtitle language:\n\n")
+
+		start, end := pageBounds(s.sel.Choice, len(s.sel.Languages), s.layout.ItemsPerPage)
+		for i := start; i < end; i++ {
+			lang := s.sel.Languages[i]
+			cursor := "  "
+			if s.sel.Choice == i {
+				cursor = "> "
 			}
-		} else {
 
-			s.WriteString("Fetching available video formats for: " + m.Text + "\n")
+			b.WriteString(s.layout.Truncate(fmt.Sprintf("%s%s", cursor, subtitleLangStyle(lang.Name))) + "\n")
 		}
+		b.WriteString(pageFooter(s.sel.Choice, len(s.sel.Languages), s.layout.ItemsPerPage))
 	} else {
-		s.WriteString(m.Textarea.View())
+		b.WriteString(WarningStyle(siteOf(s.url, s.info).Label + " has no subtitles for this video"))
 	}
-	return s.String()
+	return b.String()
 }
 
-func UpdateDownloadVideo(msg tea.Msg, m AppModel) (tea.Model, tea.Cmd) {
-	var (
-		tiCmd tea.Cmd
-	)
-	m.Textarea, tiCmd = m.Textarea.Update(msg)
-	switch msg := msg.(type) {
-	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyEnter:
-			if !m.IsUrlWritten {
-				m.Text = m.Textarea.Value()
-				m.Textarea.Reset()
-				m.IsUrlWritten = true
-				m.IsTextAreaActive = false
+type thumbnailScreen struct {
+	layout *Layout
+	url    string
+	info   *VideoInfo
+	sel    *ThumbnailSelection
+}
 
-				return m, m.fetchVideoFormats(m.Text)
-			}
-			return m, nil
+func newThumbnailScreen(layout *Layout, url string) *thumbnailScreen {
+	return &thumbnailScreen{layout: layout, url: url}
+}
+
+func (s *thumbnailScreen) Init() tea.Cmd {
+	return fetchVideoInfo(s.url)
+}
+
+func (s *thumbnailScreen) Title() string {
+	return "Thumbnails"
+}
+
+func (s *thumbnailScreen) Keys() []key.Binding {
+	if s.sel == nil || s.sel.Selected || len(s.sel.Thumbnails) == 0 {
+		return nil
+	}
+	return append(listKeys(len(s.sel.Thumbnails), s.layout.ItemsPerPage), withDesc(keys.Select, "download"))
+}
+
+func (s *thumbnailScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
+	switch msg := msg.(type) {
+	case VideoInfoMsg:
+		if msg.URL != s.url || s.sel != nil {
+			return s, nil
 		}
-	case VideoFormatMsg:
 		if msg.Error != "" {
-			m.PrintingError = true
-			m.Warning = msg.Error
-		} else {
-			m.VideoFormatSel = &VideoFormatSelection{
-				URL:     msg.URL,
-				Formats: msg.Formats,
-				Choice:  0,
-			}
+			return s, popWithWarning(msg.Error)
 		}
-		return m, nil
-	case VideoDownloadMsg:
-		if msg.Error != "" {
-			m.PrintingError = true
-			m.Warning = msg.Error
-		} else {
-			m.PrintingIsDone = true
+		s.info = msg.Info
+		s.sel = &ThumbnailSelection{
+			URL:        msg.URL,
+			Thumbnails: ParseThumbnails(msg.Info),
+		}
+	case ThumbnailDownloadMsg:
+		return s, updateThumbnailDownload(s.layout, s.sel, msg)
+	case tea.KeyMsg:
+		if s.sel == nil || s.sel.Selected || len(s.sel.Thumbnails) == 0 {
+			return s, nil
+		}
+		if movePage(msg, &s.sel.Choice, len(s.sel.Thumbnails), s.layout.ItemsPerPage) {
+			return s, nil
+		}
+		if key.Matches(msg, keys.Select) {
+			if s.sel.Thumbnails[s.sel.Choice].Ext() == "webp" {
+				return s, pushScreen(&thumbnailConvertScreen{layout: s.layout, sel: s.sel})
+			}
+			s.sel.Selected = true
+			s.sel.Downloading = true
+			return s, downloadThumbnail(s.sel.Thumbnails[s.sel.Choice], "")
 		}
-		return m, nil
 	}
-	return m, tea.Batch(tiCmd)
+	return s, nil
 }
 
-func DownloadAudioView(m AppModel) string {
-	var s strings.Builder
-	s.WriteString(TitleStyle("Download Youtube audio \U0001F3B5"))
-	s.WriteString("\n\n")
+func (s *thumbnailScreen) View() string {
+	var b strings.Builder
+	b.WriteString(infoHeader(s.layout, s.info))
 
-	debugFile, _ := os.OpenFile("debug.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if debugFile != nil {
-		fmt.Fprintf(debugFile, "DownloadAudioView called, IsUrlWritten: %t\n", m.IsUrlWritten)
-		if m.AudioFormatSel != nil {
-			fmt.Fprintf(debugFile, "AudioFormatSel: Formats=%d, Choice=%d, Selected=%t, Downloading=%t, Done=%t, Error=%t\n",
-				len(m.AudioFormatSel.Formats), m.AudioFormatSel.Choice, m.AudioFormatSel.Selected,
-				m.AudioFormatSel.Downloading, m.AudioFormatSel.Done, m.AudioFormatSel.Error)
-		} else {
-			fmt.Fprintf(debugFile, "AudioFormatSel is nil\n")
+	if s.sel == nil {
+		b.WriteString("Fetching available thumbnails for: " + s.url + "\n")
+		return b.String()
+	}
+
+	if done := thumbnailDownloadView(s.layout, s.sel); done != "" {
+		b.WriteString(done)
+	} else if len(s.sel.Thumbnails) > 0 {
+		b.WriteString("Select thumbnail size:\n\n")
+
+		start, end := pageBounds(s.sel.Choice, len(s.sel.Thumbnails), s.layout.ItemsPerPage)
+		for i := start; i < end; i++ {
+			thumb := s.sel.Thumbnails[i]
+			cursor := "  "
+			if s.sel.Choice == i {
+				cursor = "> "
+			}
+
+			line := fmt.Sprintf("%s%s %s",
+				cursor,
+				videoResolutionStyle(thumb.Resolution()),
+				videoFormatStyle(strings.ToUpper(thumb.Ext())))
+
+			b.WriteString(s.layout.Truncate(line) + "\n")
 		}
-		debugFile.Close()
+		b.WriteString(pageFooter(s.sel.Choice, len(s.sel.Thumbnails), s.layout.ItemsPerPage))
+	} else {
+		b.WriteString(WarningStyle("No thumbnails found for this video"))
 	}
+	return b.String()
+}
+
+// thumbnailConvertScreen offers to convert a WebP thumbnail before saving it
+type thumbnailConvertScreen struct {
+	layout *Layout
+	sel    *ThumbnailSelection
+	choice int
+}
 
-	if m.IsUrlWritten {
+func (s *thumbnailConvertScreen) Init() tea.Cmd {
+	return nil
+}
 
-		if m.AudioFormatSel != nil {
-			if m.AudioFormatSel.Error {
-				s.WriteString(ErrorStyle("Error: " + m.AudioFormatSel.ErrMsg))
-			} else if m.AudioFormatSel.Done {
-				s.WriteString(SuccessStyle("Audio downloaded successfully! Check assets folder"))
-			} else if m.AudioFormatSel.Downloading {
+func (s *thumbnailConvertScreen) Title() string {
+	return "Convert"
+}
 
-				s.WriteString("🔊 Downloading audio")
+func (s *thumbnailConvertScreen) Keys() []key.Binding {
+	if s.sel.Selected {
+		return nil
+	}
+	return []key.Binding{keys.Up, keys.Down, withDesc(keys.Select, "download")}
+}
 
-				spinner := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
-				frame := time.Now().UnixNano() / 100000000 % int64(len(spinner))
-				s.WriteString(" " + spinner[frame] + "\n")
-				s.WriteString("This may take a few moments...")
-			} else if len(m.AudioFormatSel.Formats) > 0 {
-				s.WriteString("Select audio format:\n\n")
+func (s *thumbnailConvertScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
+	switch msg := msg.(type) {
+	case ThumbnailDownloadMsg:
+		return s, updateThumbnailDownload(s.layout, s.sel, msg)
+	case tea.KeyMsg:
+		if s.sel.Selected {
+			return s, nil
+		}
+		switch {
+		case key.Matches(msg, keys.Down):
+			if len(ThumbnailConversions) > s.choice+1 {
+				s.choice++
+			}
+		case key.Matches(msg, keys.Up):
+			if s.choice > 0 {
+				s.choice--
+			}
+		case key.Matches(msg, keys.Select):
+			s.sel.Selected = true
+			s.sel.Downloading = true
+			convertTo := ThumbnailConversions[s.choice].View
+			return s, downloadThumbnail(s.sel.Thumbnails[s.sel.Choice], convertTo)
+		}
+	}
+	return s, nil
+}
 
-				totalItems := len(m.AudioFormatSel.Formats)
-				itemsPerPage := m.ItemsPerPage
-				totalPages := (totalItems + itemsPerPage - 1) / itemsPerPage
-				currentPage := m.Page
+func (s *thumbnailConvertScreen) View() string {
+	if done := thumbnailDownloadView(s.layout, s.sel); done != "" {
+		return done
+	}
 
-				if currentPage >= totalPages {
-					currentPage = totalPages - 1
-				}
-				if currentPage < 0 {
-					currentPage = 0
-				}
+	var b strings.Builder
+	b.WriteString("This thumbnail is a WebP image:\n\n")
+	b.WriteString(fmt.Sprintf(
+		strings.Repeat("%s\n", len(ThumbnailConversions)),
+		destructureOptions(ThumbnailConversions, s.choice)...,
+	))
+	return b.String()
+}
 
-				startIdx := currentPage * itemsPerPage
-				endIdx := startIdx + itemsPerPage
-				if endIdx > totalItems {
-					endIdx = totalItems
-				}
+// updateThumbnailDownload is shared by the screens showing a thumbnail download, only the
+// first one to get the result shows the files
+func updateThumbnailDownload(layout *Layout, sel *ThumbnailSelection, msg ThumbnailDownloadMsg) tea.Cmd {
+	if sel == nil || !sel.Downloading || msg.URL != sel.Thumbnails[sel.Choice].URL {
+		return nil
+	}
+	sel.Downloading = false
+	sel.File = msg.File
+	if msg.Error != "" {
+		sel.Error = true
+		sel.ErrMsg = msg.Error
+		return nil
+	}
+	sel.Done = true
+	return showFiles(layout, "Thumbnail saved", []string{msg.File}, 0)
+}
 
-				for i := startIdx; i < endIdx; i++ {
-					format := m.AudioFormatSel.Formats[i]
-					cursor := "  "
-					if m.AudioFormatSel.Choice == i {
-						cursor = "> "
-					}
+// thumbnailDownloadView is the download progress or result, empty before one was started
+func thumbnailDownloadView(layout *Layout, sel *ThumbnailSelection) string {
+	if sel.Error {
+		return ErrorStyle(layout.Wrap("Error: " + sel.ErrMsg))
+	} else if sel.Done {
+		return SuccessStyle(layout.Wrap("Thumbnail saved to " + sel.File))
+	} else if sel.Downloading {
+		return "🖼️ Downloading thumbnail " + spinnerFrame() + "\nThis may take a few moments..."
+	}
+	return ""
+}
 
-					line := fmt.Sprintf("%s%s %s %s",
-						cursor,
-						audioQualityStyle(format.Quality),
-						audioFormatStyle(format.Format),
-						audioFileSizeStyle(format.Filesize))
+type chaptersScreen struct {
+	layout *Layout
+	url    string
+	info   *VideoInfo
+	sel    *ChapterSelection
+}
 
-					s.WriteString(line + "\n")
-				}
+func newChaptersScreen(layout *Layout, url string) *chaptersScreen {
+	return &chaptersScreen{layout: layout, url: url}
+}
 
-				if totalPages > 1 {
-					s.WriteString("\n")
-					s.WriteString(fmt.Sprintf("Page %d of %d | ", currentPage+1, totalPages))
-					if currentPage > 0 {
-						s.WriteString("<-- Previous (h) ")
-					}
-					if currentPage < totalPages-1 {
-						s.WriteString("Next (l) -->")
-					}
-				}
+func (s *chaptersScreen) Init() tea.Cmd {
+	return fetchVideoInfo(s.url)
+}
 
-				s.WriteString("\n\n(Press \u2191/\u2193 to select, Enter to download, h/l for pagination)")
-			} else {
-				s.WriteString("Loading available formats...")
-			}
-		} else {
+func (s *chaptersScreen) Title() string {
+	return "Chapters"
+}
 
-			s.WriteString("Fetching available audio formats for: " + m.Text + "\n")
-		}
-	} else {
-		s.WriteString(m.Textarea.View())
+func (s *chaptersScreen) Keys() []key.Binding {
+	if s.sel == nil || len(s.sel.Info.Chapters) == 0 {
+		return nil
 	}
-	return s.String()
+	return append(listKeys(len(s.sel.Info.Chapters), s.layout.ItemsPerPage), keys.Mark, keys.MarkAll, withDesc(keys.Select, "continue"))
 }
 
-func UpdateDownloadAudio(msg tea.Msg, m AppModel) (tea.Model, tea.Cmd) {
-	var (
-		tiCmd tea.Cmd
-	)
-	m.Textarea, tiCmd = m.Textarea.Update(msg)
+func (s *chaptersScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
 	switch msg := msg.(type) {
+	case VideoInfoMsg:
+		if msg.URL != s.url || s.sel != nil {
+			return s, nil
+		}
+		if msg.Error != "" {
+			return s, popWithWarning(msg.Error)
+		}
+		s.info = msg.Info
+		s.sel = &ChapterSelection{
+			URL:    msg.URL,
+			Info:   msg.Info,
+			Marked: make([]bool, len(msg.Info.Chapters)),
+		}
 	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyEnter:
-			if !m.IsUrlWritten {
-				m.Text = m.Textarea.Value()
-				m.Textarea.Reset()
-				m.IsUrlWritten = true
-				m.IsTextAreaActive = false
-
-				debugFile, _ := os.OpenFile("debug.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-				if debugFile != nil {
-					fmt.Fprintf(debugFile, "Calling fetchAudioFormats with URL: %s\n", m.Text)
-					debugFile.Close()
-				}
-				return m, m.fetchAudioFormats(m.Text)
+		if s.sel == nil || len(s.sel.Info.Chapters) == 0 {
+			return s, nil
+		}
+		chapters := s.sel.Info.Chapters
+		if movePage(msg, &s.sel.Choice, len(chapters), s.layout.ItemsPerPage) {
+			return s, nil
+		}
+		switch {
+		case key.Matches(msg, keys.Mark):
+			s.sel.Marked[s.sel.Choice] = !s.sel.Marked[s.sel.Choice]
+		case key.Matches(msg, keys.MarkAll):
+			all := len(s.sel.MarkedIndices()) == len(chapters)
+			for i := range s.sel.Marked {
+				s.sel.Marked[i] = !all
 			}
-			return m, nil
+		case key.Matches(msg, keys.Select):
+			return s, tea.Batch(warn(""), pushScreen(&chapterActionsScreen{layout: s.layout, sel: s.sel}))
 		}
-	case AudioFormatMsg:
+	}
+	return s, nil
+}
+
+func (s *chaptersScreen) View() string {
+	var b strings.Builder
+	b.WriteString(infoHeader(s.layout, s.info))
 
-		debugFile, _ := os.OpenFile("debug.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		if debugFile != nil {
-			fmt.Fprintf(debugFile, "Received AudioFormatMsg, Error: %s, Formats count: %d\n", msg.Error, len(msg.Formats))
-			debugFile.Close()
+	if s.sel == nil {
+		b.WriteString("Fetching chapters for: " + s.url + "\n")
+		return b.String()
+	}
+
+	info := s.sel.Info
+	if len(info.Chapters) == 0 {
+		b.WriteString(WarningStyle(s.layout.Wrap("\"" + info.Title + "\" has no chapters")))
+		return b.String()
+	}
+
+	start, end := pageBounds(s.sel.Choice, len(info.Chapters), s.layout.ItemsPerPage)
+	for i := start; i < end; i++ {
+		chapter := info.Chapters[i]
+		cursor := "  "
+		if s.sel.Choice == i {
+			cursor = "> "
+		}
+
+		mark := "[ ]"
+		if s.sel.Marked[i] {
+			mark = "[x]"
 		}
 
+		line := fmt.Sprintf("%s%s %s %s %s",
+			cursor,
+			mark,
+			chapterNumberStyle(fmt.Sprintf("%02d", i+1)),
+			chapterTitleStyle(chapter.Title),
+			chapterTimeStyle(utils.FormatDuration(chapter.StartTime)+" - "+utils.FormatDuration(chapter.EndTime)))
+
+		b.WriteString(s.layout.Truncate(line) + "\n")
+	}
+	b.WriteString(pageFooter(s.sel.Choice, len(info.Chapters), s.layout.ItemsPerPage))
+	return b.String()
+}
+
+// chapterActionsScreen downloads or splits the chapters marked on the chapters screen
+type chapterActionsScreen struct {
+	layout      *Layout
+	sel         *ChapterSelection
+	choice      int
+	downloading bool
+	done        bool
+	err         string
+	outputDir   string
+	files       []string
+	// jobID is the job of the queue downloading the chapters
+	jobID int
+}
+
+func (s *chapterActionsScreen) Init() tea.Cmd {
+	return nil
+}
+
+func (s *chapterActionsScreen) Title() string {
+	return "Actions"
+}
+
+func (s *chapterActionsScreen) Keys() []key.Binding {
+	if s.downloading || s.done || s.err != "" {
+		return nil
+	}
+	return []key.Binding{keys.Up, keys.Down, keys.Cue, withDesc(keys.Select, "run")}
+}
+
+func (s *chapterActionsScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
+	switch msg := msg.(type) {
+	case ChapterDownloadMsg:
+		if !s.downloading || msg.JobID != s.jobID {
+			return s, nil
+		}
+		s.downloading = false
+		s.outputDir = msg.Dir
+		s.files = msg.Files
 		if msg.Error != "" {
-			m.Warning = msg.Error
-			m.IsUrlWritten = false
-			m.PrintingError = true
+			s.err = msg.Error
 		} else {
-			m.AudioFormatSel = &AudioFormatSelection{
-				URL:     msg.URL,
-				Formats: msg.Formats,
-				Choice:  0,
+			s.done = true
+			return s, showFiles(s.layout, "Chapters saved", msg.Files, 0)
+		}
+	case tea.KeyMsg:
+		if s.downloading || s.done || s.err != "" {
+			return s, nil
+		}
+		switch {
+		case key.Matches(msg, keys.Down):
+			if len(ChapterActions) > s.choice+1 {
+				s.choice++
+			}
+		case key.Matches(msg, keys.Up):
+			if s.choice > 0 {
+				s.choice--
 			}
+		case key.Matches(msg, keys.Cue):
+			s.sel.WriteCue = !s.sel.WriteCue
+		case key.Matches(msg, keys.Select):
+			action := ChapterActions[s.choice].View
+			indices := s.sel.MarkedIndices()
+			if len(indices) == 0 && (action == "chapters-audio" || action == "chapters-video") {
+				return s, popWithWarning("No chapters selected, mark some with space first")
+			}
+			chapters := ChapterJob{Indices: indices}
+			if action == "split-audio" || action == "split-video" {
+				chapters = ChapterJob{Split: true, Cue: action == "split-audio" && s.sel.WriteCue}
+			}
+			job := NewChapterJob(s.sel.URL, s.sel.Info, action == "chapters-audio" || action == "split-audio", chapters)
+			s.downloading = true
+			s.jobID = SharedQueue().Add(job).ID
+			return s, downloadChapters(s.jobID, s.sel.Info)
 		}
-		return m, nil
 	}
-	return m, tea.Batch(tiCmd)
+	return s, nil
 }
 
-func DownloadSubtitlesView(m AppModel) string {
-	var s strings.Builder
-	s.WriteString(TitleStyle("Download Youtube subtitles \U0001F4DD"))
-	s.WriteString("\n\n")
+func (s *chapterActionsScreen) View() string {
+	var b strings.Builder
 
-	if m.IsUrlWritten {
+	if s.err != "" {
+		b.WriteString(ErrorStyle(s.layout.Wrap("Error: " + s.err)))
+	} else if s.done {
+		b.WriteString(SuccessStyle(s.layout.Wrap(fmt.Sprintf("Saved %d files to %s", len(s.files), s.outputDir))))
+		b.WriteString("\n\n")
+		for _, file := range s.files {
+			b.WriteString(s.layout.Truncate("  "+filepath.Base(file)) + "\n")
+		}
+	} else if job, _ := SharedQueue().Get(s.jobID); s.downloading && !job.ScheduledFor.IsZero() {
+		b.WriteString("⏰ Scheduled for " + scheduleLabel(job.ScheduledFor) + "\n")
+		b.WriteString(s.layout.Wrap("Downloads start between " + strings.Join(config.Current().Schedule, ", ") + ", it's in the queue until then"))
+	} else if s.downloading {
+		b.WriteString(fmt.Sprintf("✂️ Downloading chapters %.0f%% %s\n", job.Progress, spinnerFrame()))
+		b.WriteString("This may take a few moments...")
+	} else {
+		marked := len(s.sel.MarkedIndices())
+		b.WriteString(fmt.Sprintf("%d of %d chapters selected\n\n", marked, len(s.sel.Info.Chapters)))
+
+		b.WriteString(fmt.Sprintf(
+			strings.Repeat("%s\n", len(ChapterActions)),
+			destructureOptions(ChapterActions, s.choice)...,
+		))
+		b.WriteString("\n")
+		b.WriteString(checkbox("Write .cue sheet when splitting audio (saved as FLAC)", s.sel.WriteCue))
+	}
+	return b.String()
+}
 
-		if m.SubtitleSel != nil {
-			if m.SubtitleSel.Error {
-				s.WriteString(ErrorStyle("Error: " + m.SubtitleSel.ErrMsg))
-			} else if m.SubtitleSel.Done {
-				s.WriteString(SuccessStyle("Subtitles downloaded successfully! Check assets folder"))
-			} else if m.SubtitleSel.Downloading {
+type ClipRangeMsg struct {
+	Clip *TimeRange
+}
 
-				selectedLang := m.SubtitleSel.Languages[m.SubtitleSel.Choice].Name
-				s.WriteString("📝 Downloading " + selectedLang + " subtitles")
+// clipScreen edits the clip range of the audio and video pickers and hands it back with a ClipRangeMsg
+type clipScreen struct {
+	layout *Layout
+	url    string
+	info   *VideoInfo
+	input  textinput.Model
+	err    string
+}
 
-				spinner := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
-				frame := time.Now().UnixNano() / 100000000 % int64(len(spinner))
-				s.WriteString(" " + spinner[frame] + "\n")
-				s.WriteString("This may take a few moments...")
-			} else if len(m.SubtitleSel.Languages) > 0 {
-				s.WriteString("Select subtitle language:\n\n")
+func newClipScreen(layout *Layout, url string, clip *TimeRange, info *VideoInfo) *clipScreen {
+	ci := textinput.New()
+	ci.Placeholder = "1:30-2:45"
+	ci.Prompt = "┃ "
+	ci.CharLimit = 280
+	ci.Width = inputWidth(layout)
+
+	if clip != nil {
+		value := utils.FormatDuration(clip.Start) + "-"
+		if clip.HasEnd {
+			value += utils.FormatDuration(clip.End)
+		}
+		ci.SetValue(value)
+	}
+	ci.Focus()
 
-				totalItems := len(m.SubtitleSel.Languages)
-				itemsPerPage := m.ItemsPerPage
-				totalPages := (totalItems + itemsPerPage - 1) / itemsPerPage
-				currentPage := m.Page
+	return &clipScreen{layout: layout, url: url, info: info, input: ci}
+}
 
-				if currentPage >= totalPages {
-					currentPage = totalPages - 1
-				}
-				if currentPage < 0 {
-					currentPage = 0
-				}
+func (s *clipScreen) Init() tea.Cmd {
+	return textinput.Blink
+}
 
-				startIdx := currentPage * itemsPerPage
-				endIdx := startIdx + itemsPerPage
-				if endIdx > totalItems {
-					endIdx = totalItems
-				}
+func (s *clipScreen) Title() string {
+	return "Clip range"
+}
 
-				for i := startIdx; i < endIdx; i++ {
-					lang := m.SubtitleSel.Languages[i]
-					cursor := "  "
-					if m.SubtitleSel.Choice == i {
-						cursor = "> "
-					}
+func (s *clipScreen) Keys() []key.Binding {
+	return []key.Binding{withDesc(keys.Select, "apply"), withDesc(keys.Back, "cancel when empty")}
+}
 
-					line := fmt.Sprintf("%s%s", cursor, subtitleLangStyle(lang.Name))
+func (s *clipScreen) Typing() bool {
+	return true
+}
 
-					s.WriteString(line + "\n")
-				}
+func (s *clipScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
+	switch msg := msg.(type) {
+	case VideoInfoMsg:
+		if msg.URL == s.url && msg.Info != nil {
+			s.info = msg.Info
+		}
+		return s, nil
+	case tea.WindowSizeMsg:
+		s.input.Width = inputWidth(s.layout)
+		return s, nil
+	case tea.KeyMsg:
+		switch {
+		case typedKey(msg):
+		case key.Matches(msg, keys.Select):
+			clip, err := ParseTimeRange(s.input.Value())
+			if err == nil && clip != nil && s.info != nil {
+				err = clip.Validate(s.info.Duration)
+			}
+			if err != nil {
+				s.err = err.Error()
+				return s, nil
+			}
+			return s, popWithResult(ClipRangeMsg{Clip: clip})
+		case key.Matches(msg, keys.Back):
+			if s.input.Value() == "" {
+				return s, popScreen()
+			}
+		}
+	}
 
-				if totalPages > 1 {
-					s.WriteString("\n")
-					s.WriteString(fmt.Sprintf("Page %d of %d | ", currentPage+1, totalPages))
-					if currentPage > 0 {
-						s.WriteString("<-- Previous (h) ")
-					}
-					if currentPage < totalPages-1 {
-						s.WriteString("Next (l) -->")
-					}
-				}
+	var cmd tea.Cmd
+	s.input, cmd = s.input.Update(msg)
+	return s, cmd
+}
 
-				s.WriteString("\n\n(Press ↑/↓ to select, Enter to download, h/l for pagination)")
-			} else {
-				s.WriteString("Loading available languages...")
-			}
-		} else {
+func (s *clipScreen) View() string {
+	var b strings.Builder
+	b.WriteString("Clip range, e.g. 1:30-2:45, 1:30- or -2:45. A URL with t= sets the start.\n")
+	if s.info != nil && s.info.Duration > 0 {
+		b.WriteString("Video length: " + utils.FormatDuration(s.info.Duration) + "\n")
+	}
+	b.WriteString("\n" + s.input.View() + "\n")
+	if s.err != "" {
+		b.WriteString("\n" + ErrorStyle(s.layout.Wrap(s.err)) + "\n")
+	}
+	b.WriteString("\nLeave it empty for the whole video.")
+	return b.String()
+}
 
-			s.WriteString("Fetching available subtitle languages for: " + m.Text + "\n")
-		}
-	} else {
-		s.WriteString(m.Textarea.View())
+// checkClip drops a clip taken from the URL's t= once the video turns out to be shorter
+func checkClip(clip *TimeRange, info *VideoInfo) (*TimeRange, tea.Cmd) {
+	if clip == nil {
+		return nil, nil
 	}
-	return s.String()
+	if err := clip.Validate(info.Duration); err != nil {
+		return nil, warn("Ignoring clip range from URL: " + err.Error())
+	}
+	return clip, nil
 }
 
-func UpdateDownloadSubtitles(msg tea.Msg, m AppModel) (tea.Model, tea.Cmd) {
-	var (
-		tiCmd tea.Cmd
-	)
-	m.Textarea, tiCmd = m.Textarea.Update(msg)
+// infoHeader is the info panel above the pickers, just the title in compact mode
+func infoHeader(layout *Layout, info *VideoInfo) string {
+	if info == nil {
+		return ""
+	}
+	if layout.Compact {
+		return layout.Truncate(infoTitleStyle(info.Title)) + "\n\n"
+	}
+	return VideoInfoPanel(info, layout.ContentWidth()) + "\n\n"
+}
+
+// inputWidth stretches text inputs over the content width, they start at 50 wide
+func inputWidth(layout *Layout) int {
+	width := layout.ContentWidth()
+	if width == 0 {
+		return 50
+	}
+	if width < 20 {
+		return 20
+	}
+	return width
+}
+
+type subscriptionsScreen struct {
+	layout *Layout
+	sel    *SubscriptionSelection
+	err    string
+}
+
+func newSubscriptionsScreen(layout *Layout) *subscriptionsScreen {
+	sel, err := newSubscriptionSelection()
+	s := &subscriptionsScreen{layout: layout, sel: sel}
+	if err != nil {
+		s.err = err.Error()
+	}
+	return s
+}
+
+func (s *subscriptionsScreen) Init() tea.Cmd {
+	if s.err != "" {
+		return warn(s.err)
+	}
+	return nil
+}
+
+func (s *subscriptionsScreen) Title() string {
+	return "Channel & playlist subscriptions 🔁"
+}
+
+func (s *subscriptionsScreen) Keys() []key.Binding {
+	if s.sel == nil || len(s.sel.Subscriptions) == 0 {
+		return nil
+	}
+	return append(listKeys(len(s.sel.Subscriptions), s.layout.ItemsPerPage), withDesc(keys.Select, "sync"), keys.SyncAll, keys.Check)
+}
+
+func (s *subscriptionsScreen) Update(msg tea.Msg) (Screen, tea.Cmd) {
+	sel := s.sel
+	if sel == nil {
+		return s, nil
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyEnter:
-			if !m.IsUrlWritten {
-				m.Text = m.Textarea.Value()
-				m.Textarea.Reset()
-				m.IsUrlWritten = true
-				m.IsTextAreaActive = false
-
-				return m, m.fetchSubtitleLanguages(m.Text)
+		if len(sel.Subscriptions) == 0 {
+			return s, nil
+		}
+		if movePage(msg, &sel.Choice, len(sel.Subscriptions), s.layout.ItemsPerPage) {
+			return s, nil
+		}
+		switch {
+		case key.Matches(msg, keys.Select):
+			sub := sel.Subscriptions[sel.Choice]
+			if !sel.Syncing[sub.Name] {
+				sel.Syncing[sub.Name] = true
+				delete(sel.Errors, sub.Name)
+				return s, syncSubscription(sub)
+			}
+		case key.Matches(msg, keys.SyncAll):
+			var cmds []tea.Cmd
+			for _, sub := range sel.Subscriptions {
+				if !sel.Syncing[sub.Name] {
+					sel.Syncing[sub.Name] = true
+					delete(sel.Errors, sub.Name)
+					cmds = append(cmds, syncSubscription(sub))
+				}
+			}
+			return s, tea.Sequence(cmds...)
+		case key.Matches(msg, keys.Check):
+			sub := sel.Subscriptions[sel.Choice]
+			if !sel.Checking[sub.Name] {
+				sel.Checking[sub.Name] = true
+				return s, checkSubscription(sub)
 			}
-			return m, nil
 		}
-	case SubtitleLangMsg:
+	case SubscriptionSyncMsg:
+		delete(sel.Syncing, msg.Name)
+		delete(sel.Pending, msg.Name)
 		if msg.Error != "" {
-			m.PrintingError = true
-			m.Warning = msg.Error
-		} else {
-			m.SubtitleSel = &SubtitleSelection{
-				URL:       msg.URL,
-				Languages: msg.Languages,
-				Choice:    0,
-			}
+			sel.Errors[msg.Name] = msg.Error
 		}
-		return m, nil
-	case SubtitleDownloadMsg:
+		if subs, err := LoadSubscriptions(); err == nil {
+			sel.Subscriptions = subs
+		}
+	case SubscriptionCheckMsg:
+		delete(sel.Checking, msg.Name)
 		if msg.Error != "" {
-			m.PrintingError = true
-			m.Warning = msg.Error
+			sel.Errors[msg.Name] = msg.Error
 		} else {
-			m.PrintingIsDone = true
+			sel.Pending[msg.Name] = msg.Pending
+		}
+	}
+	return s, nil
+}
+
+func (s *subscriptionsScreen) View() string {
+	var b strings.Builder
+
+	sel := s.sel
+	if sel == nil {
+		return b.String()
+	}
+
+	if len(sel.Subscriptions) == 0 {
+		b.WriteString("No subscriptions yet. Add one from the command line:\n\n")
+		b.WriteString("  bubly sync add -kind audio https://www.youtube.com/@channel\n")
+		return b.String()
+	}
+
+	start, end := pageBounds(sel.Choice, len(sel.Subscriptions), s.layout.ItemsPerPage)
+	for i := start; i < end; i++ {
+		sub := sel.Subscriptions[i]
+		cursor := "  "
+		if sel.Choice == i {
+			cursor = "> "
+		}
+
+		status := fmt.Sprintf("last sync %s, %d new", utils.FormatAgo(sub.LastSync), sub.LastNew)
+		if pending, ok := sel.Pending[sub.Name]; ok {
+			status += fmt.Sprintf(", %d waiting", pending)
+		}
+		if sel.Syncing[sub.Name] {
+			status = "syncing " + spinnerFrame()
+		} else if sel.Checking[sub.Name] {
+			status = "checking " + spinnerFrame()
+		}
+
+		line := fmt.Sprintf("%s%s %s %s",
+			cursor,
+			subtitleLangStyle(sub.Name),
+			videoFormatStyle(string(sub.Kind)),
+			videoFileSizeStyle(status))
+		b.WriteString(s.layout.Truncate(line) + "\n")
+
+		if errMsg := sel.Errors[sub.Name]; errMsg != "" {
+			b.WriteString(s.layout.Truncate("    "+urlErrorStyle(errMsg)) + "\n")
+		} else if sub.LastError != "" && !sel.Syncing[sub.Name] {
+			b.WriteString(s.layout.Truncate("    "+urlErrorStyle(sub.LastError)) + "\n")
 		}
-		return m, nil
 	}
-	return m, tea.Batch(tiCmd)
+	b.WriteString(pageFooter(sel.Choice, len(sel.Subscriptions), s.layout.ItemsPerPage))
+	return b.String()
 }
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/config"
+)
+
+// ytdlpCommand is used for every yt-dlp invocation so the active profile's cookies always apply
+func ytdlpCommand(path string, args ...string) *exec.Cmd {
+	return exec.Command(path, append(authArgs(), args...)...)
+}
+
+func authArgs() []string {
+	auth := config.Current().ActiveProfile().Auth
+	if auth.CookiesFile != "" {
+		return []string{"--cookies", auth.CookiesFile}
+	}
+	if auth.Browser != "" {
+		return []string{"--cookies-from-browser", auth.Browser}
+	}
+	return nil
+}
+
+var authFailures = []struct {
+	pattern string
+	reason  string
+}{
+	{"sign in to confirm your age", "age-restricted"},
+	{"confirm you're not a bot", "behind YouTube's bot check"},
+	{"confirm you’re not a bot", "behind YouTube's bot check"},
+	{"members-only", "members-only"},
+	{"join this channel", "members-only"},
+	{"private video", "private"},
+	{"video is private", "private"},
+	{"login required", "only available when signed in"},
+	{"requires authentication", "only available when signed in"},
+	{"use --cookies", "only available when signed in"},
+}
+
+// AuthHint turns yt-dlp's sign-in failures into a message pointing at the auth setting
+func AuthHint(stderr string) string {
+	lower := strings.ToLower(stderr)
+	for _, failure := range authFailures {
+		if !strings.Contains(lower, failure.pattern) {
+			continue
+		}
+
+		cfg := config.Current()
+		if len(authArgs()) > 0 {
+			return fmt.Sprintf("This video is %s and the cookies of profile %q were rejected. They may be expired, export fresh ones and update profiles.%s.auth in %s.",
+				failure.reason, cfg.Profile, cfg.Profile, config.Path())
+		}
+		return fmt.Sprintf("This video is %s. Sign in by setting profiles.%s.auth.cookies_file (a Netscape cookies.txt) or cookies_from_browser (e.g. \"firefox\") in %s.",
+			failure.reason, cfg.Profile, config.Path())
+	}
+	return ""
+}
+
+func ytdlpError(action string, err error, stderr string) error {
+	if hint := AuthHint(stderr); hint != "" {
+		return &JobError{Class: "auth", Message: hint}
+	}
+	return &JobError{Class: ytdlpErrorClass(stderr), Message: fmt.Sprintf("Error %s: %v. Check output.log for details.", action, err)}
+}
+
+// titlePrefix marks the line titleArgs has yt-dlp print the title on, once the download starts
+const titlePrefix = "bubly-title:"
+
+// titleArgs go with --no-quiet, --print would otherwise silence the rest of yt-dlp's output
+var titleArgs = []string{"--print", "before_dl:" + titlePrefix + "%(title)s"}
+
+// printedTitle finds the title titleArgs printed
+func printedTitle(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if title, ok := strings.CutPrefix(strings.TrimSpace(line), titlePrefix); ok {
+			return title
+		}
+	}
+	return ""
+}
+
+// JobError is a failure with the class reports group failures by
+type JobError struct {
+	Class   string
+	Message string
+}
+
+func (e *JobError) Error() string {
+	return e.Message
+}
+
+// errorClasses are checked in order, the first one found in yt-dlp's errors is the class
+var errorClasses = []struct {
+	pattern string
+	class   string
+}{
+	{"Unsupported URL", "unsupported"},
+	{"Requested format is not available", "format"},
+	{"HTTP Error 429", "rate_limited"},
+	{"Too Many Requests", "rate_limited"},
+	{"HTTP Error 403", "forbidden"},
+	{"Video unavailable", "unavailable"},
+	{"not available", "unavailable"},
+	{"has been removed", "unavailable"},
+	{"timed out", "network"},
+	{"Connection", "network"},
+	{"getaddrinfo", "network"},
+	{"Name or service not known", "network"},
+	{"Unable to download", "network"},
+	{"Postprocessing", "postprocessing"},
+	{"ffmpeg", "postprocessing"},
+}
+
+func ytdlpErrorClass(stderr string) string {
+	for _, c := range errorClasses {
+		if strings.Contains(stderr, c.pattern) {
+			return c.class
+		}
+	}
+	return "ytdlp"
+}
+
+// errorClass is the class of a job's error, "other" for errors that aren't yt-dlp's
+func errorClass(err error) string {
+	var jobErr *JobError
+	if errors.As(err, &jobErr) {
+		return jobErr.Class
+	}
+	return "other"
+}
+
+// AuthWarning reports a configured cookies file that doesn't exist, before any request fails because of it
+func AuthWarning() string {
+	auth := config.Current().ActiveProfile().Auth
+	if auth.CookiesFile == "" {
+		return ""
+	}
+	if _, err := os.Stat(auth.CookiesFile); err != nil {
+		return fmt.Sprintf("Cookies file %s of profile %q was not found, restricted videos will fail", auth.CookiesFile, config.Current().Profile)
+	}
+	return ""
+}
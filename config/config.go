@@ -0,0 +1,486 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/AbdelilahOu/Bubly-cli-app/utils"
+)
+
+const DefaultProfile = "default"
+
+type Auth struct {
+	// CookiesFile is a Netscape formatted cookies.txt, passed as --cookies
+	CookiesFile string `json:"cookies_file,omitempty"`
+	// Browser is passed as --cookies-from-browser, e.g. "firefox" or "chrome:Profile 1"
+	Browser string `json:"cookies_from_browser,omitempty"`
+}
+
+type Profile struct {
+	Auth Auth `json:"auth"`
+}
+
+// Site overrides settings for the downloads of one site, keyed by yt-dlp's extractor name
+type Site struct {
+	AudioFormat string `json:"audio_format,omitempty"`
+	VideoFormat string `json:"video_format,omitempty"`
+}
+
+// SponsorBlock finds the sponsor reads and other segments of YouTube videos
+type SponsorBlock struct {
+	// API is the base URL of the SponsorBlock server, e.g. a mirror or a local stand-in
+	API string `json:"api,omitempty"`
+	// Categories are the kinds of segments to act on, see SponsorBlockCategories
+	Categories []string `json:"categories,omitempty"`
+	// Mode is what downloads do with the segments unless told otherwise: "remove" cuts them
+	// out, "mark" makes them chapters and "" leaves them alone
+	Mode string `json:"mode,omitempty"`
+}
+
+const DefaultSponsorBlockAPI = "https://sponsor.ajay.app"
+
+// SponsorBlockCategories are the segment categories of the SponsorBlock API
+var SponsorBlockCategories = []string{"sponsor", "selfpromo", "interaction", "intro", "outro", "preview", "music_offtopic", "filler"}
+
+// APIBase is the server to ask, without a trailing slash
+func (s SponsorBlock) APIBase() string {
+	if s.API == "" {
+		return DefaultSponsorBlockAPI
+	}
+	return strings.TrimRight(s.API, "/")
+}
+
+// CategoryList is the categories to act on, sponsors and the like when none are set
+func (s SponsorBlock) CategoryList() []string {
+	if len(s.Categories) == 0 {
+		return []string{"sponsor", "selfpromo", "interaction"}
+	}
+	return s.Categories
+}
+
+// ParseRate reads a speed like yt-dlp's --limit-rate takes, "500K", "2M" or "2MiB" a second,
+// into bytes. K, M and G are binary units in either case, empty is no limit.
+func ParseRate(s string) (int64, error) {
+	rate := strings.TrimSuffix(strings.TrimSpace(s), "/s")
+	if rate == "" {
+		return 0, nil
+	}
+	switch unit := strings.ToUpper(rate[len(rate)-1:]); {
+	case unit == "K", unit == "M", unit == "G":
+		rate = rate[:len(rate)-1] + unit + "iB"
+	case strings.IndexFunc(rate, unicode.IsLetter) < 0:
+		rate += "B"
+	}
+	bytes, ok := utils.ParseSize(rate)
+	if !ok || bytes <= 0 {
+		return 0, fmt.Errorf("%q is not a speed like 500K or 2M", s)
+	}
+	return bytes, nil
+}
+
+// Window is a daily time span in minutes since midnight, it ends the next day when End is
+// before Start
+type Window struct {
+	Start, End int
+}
+
+// ParseWindow reads a window like "22:00-06:00"
+func ParseWindow(s string) (Window, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return Window{}, fmt.Errorf("%q is not a window like 22:00-06:00", s)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return Window{}, err
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return Window{}, err
+	}
+	if start == end {
+		return Window{}, fmt.Errorf("%q starts and ends at the same time", s)
+	}
+	return Window{Start: start, End: end}, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time like 22:00", strings.TrimSpace(s))
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Contains reports whether a time of day, in minutes since midnight, is in the window
+func (w Window) Contains(minute int) bool {
+	if w.Start < w.End {
+		return minute >= w.Start && minute < w.End
+	}
+	return minute >= w.Start || minute < w.End
+}
+
+// Windows are the parsed Schedule, windows that don't parse are left out
+func (c *Config) Windows() []Window {
+	var windows []Window
+	for _, s := range c.Schedule {
+		if window, err := ParseWindow(s); err == nil {
+			windows = append(windows, window)
+		}
+	}
+	return windows
+}
+
+// Hooks are shell commands run on queue events, with the job in BUBLY_JOB_* variables and as
+// JSON on stdin. A start or success hook that fails fails its job.
+type Hooks struct {
+	OnStart   string `json:"on_start,omitempty"`
+	OnSuccess string `json:"on_success,omitempty"`
+	OnFailure string `json:"on_failure,omitempty"`
+	// OnDrained runs once the queue has nothing left to run, with all the jobs since the last time
+	OnDrained string `json:"on_drained,omitempty"`
+	// Timeout is how long a hook may run in seconds, DefaultHookTimeout when 0
+	Timeout int `json:"timeout,omitempty"`
+}
+
+const DefaultHookTimeout = 60
+
+// Command is the hook of an event: "start", "success", "failure" or "drained"
+func (h Hooks) Command(event string) string {
+	switch event {
+	case "start":
+		return h.OnStart
+	case "success":
+		return h.OnSuccess
+	case "failure":
+		return h.OnFailure
+	case "drained":
+		return h.OnDrained
+	}
+	return ""
+}
+
+func (h Hooks) TimeoutDuration() time.Duration {
+	if h.Timeout == 0 {
+		return DefaultHookTimeout * time.Second
+	}
+	return time.Duration(h.Timeout) * time.Second
+}
+
+// Notifications are sent once the downloads that were running have all finished
+type Notifications struct {
+	// Bell rings the terminal bell
+	Bell bool `json:"bell"`
+	// Terminal is the notification escape to send: "9" (iTerm2, Windows Terminal, WezTerm),
+	// "777" (urxvt, foot, Ghostty) or "" for none
+	Terminal string `json:"terminal,omitempty"`
+	// Desktop sends a freedesktop notification over D-Bus, on Linux and the BSDs
+	Desktop bool `json:"desktop,omitempty"`
+	// Progress shows the download progress on the taskbar or tab while downloads run
+	Progress bool `json:"progress,omitempty"`
+}
+
+type Config struct {
+	Profile  string             `json:"profile"`
+	Profiles map[string]Profile `json:"profiles"`
+	// Theme is a built-in theme name or a theme file, see app.LoadTheme
+	Theme string `json:"theme,omitempty"`
+	// Background is "light", "dark" or "auto" (the default) to ask the terminal
+	Background string `json:"background,omitempty"`
+	// Keymap is the key layout to start from: "vim" (the default), "arrows" or "emacs"
+	Keymap string `json:"keymap,omitempty"`
+	// Keys rebinds single actions, e.g. {"quit": ["ctrl+q"]}, an empty list turns one off
+	Keys map[string][]string `json:"keys,omitempty"`
+
+	// OutputDir is where downloads go, in subfolders like audio and chapters
+	OutputDir string `json:"output_dir"`
+	// PageSize is the number of items per page, 0 fits pages to the window
+	PageSize int `json:"page_size,omitempty"`
+	// Concurrency is the number of downloads the app, batch and serve run at once
+	Concurrency int `json:"concurrency"`
+	// AudioFormat and VideoFormat are the yt-dlp formats used when none was picked
+	AudioFormat string `json:"audio_format"`
+	VideoFormat string `json:"video_format"`
+	// Compact always uses the compact layout, not only on small windows
+	Compact bool `json:"compact,omitempty"`
+	// Notifications are the ways to tell that downloads finished
+	Notifications Notifications `json:"notifications"`
+	// DiskCheck is what happens when a download may not fit on the disk: "block" (the
+	// default) stops it from starting, "warn" only says so and "off" doesn't check
+	DiskCheck string `json:"disk_check,omitempty"`
+	// SponsorBlock is where segments come from and what is done with them
+	SponsorBlock SponsorBlock `json:"sponsorblock"`
+	// RateLimit caps the speed of all downloads together, a size a second like "2MiB" or
+	// "500K", empty for no limit
+	RateLimit string `json:"rate_limit,omitempty"`
+	// Schedule are the hours downloads may start in, like ["22:00-06:00"], none for any time
+	Schedule []string `json:"schedule,omitempty"`
+	// Report is a file every run writes its jobs to when it ends, CSV for a .csv file and
+	// JSON otherwise. {time} in it is replaced by the time the run ended.
+	Report string `json:"report,omitempty"`
+	// Hooks run commands when jobs start, succeed or fail and when the queue is drained
+	Hooks Hooks `json:"hooks"`
+	// Sites are the settings for single sites, e.g. {"vimeo": {"video_format": "best"}}
+	Sites map[string]Site `json:"sites,omitempty"`
+}
+
+const MaxConcurrency = 16
+
+var (
+	mu      sync.RWMutex
+	current = Default()
+	path    = "bubly.json"
+	// profileArg is the --profile passed to Load, kept to apply it again after a Save
+	profileArg string
+)
+
+func Default() *Config {
+	return &Config{
+		Profile:     DefaultProfile,
+		Profiles:    map[string]Profile{DefaultProfile: {}},
+		OutputDir:   "assets",
+		Concurrency: 1,
+		AudioFormat: "bestaudio",
+		VideoFormat: "bestvideo*+bestaudio/best",
+		Notifications: Notifications{
+			Bell: true,
+		},
+	}
+}
+
+func Path() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return path
+}
+
+// Load reads the config file (bubly.json or $BUBLY_CONFIG) into Current.
+// A missing file is not an error, the defaults are used instead.
+// The profile can be picked with $BUBLY_PROFILE or the profile argument, which wins when set.
+// $BUBLY_THEME overrides the theme.
+func Load(profile string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if env := os.Getenv("BUBLY_CONFIG"); env != "" {
+		path = env
+	}
+	profileArg = profile
+
+	cfg, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	applyOverrides(cfg)
+	current = cfg
+	if _, ok := cfg.Profiles[cfg.Profile]; !ok {
+		return fmt.Errorf("profile %q is not defined in %s, available: %s", cfg.Profile, path, strings.Join(cfg.ProfileNames(), ", "))
+	}
+	return nil
+}
+
+// File is the config as saved, without the overrides from the environment and flags,
+// which is what should be edited and passed to Save
+func File() (*Config, error) {
+	return readFile(Path())
+}
+
+func readFile(path string) (*Config, error) {
+	cfg := Default()
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("reading %s: %v", path, err)
+		}
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]Profile{}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+func applyOverrides(cfg *Config) {
+	if env := os.Getenv("BUBLY_THEME"); env != "" {
+		cfg.Theme = env
+	}
+	if env := os.Getenv("BUBLY_PROFILE"); env != "" {
+		cfg.Profile = env
+	}
+	if profileArg != "" {
+		cfg.Profile = profileArg
+	}
+	if cfg.Profile == "" {
+		cfg.Profile = DefaultProfile
+	}
+}
+
+// Validate checks the values the app can't fall back from. Values that are left out get
+// their defaults, so only ones that are set wrong are errors.
+func (c *Config) Validate() error {
+	defaults := Default()
+	if strings.TrimSpace(c.OutputDir) == "" {
+		c.OutputDir = defaults.OutputDir
+	}
+	if c.AudioFormat == "" {
+		c.AudioFormat = defaults.AudioFormat
+	}
+	if c.VideoFormat == "" {
+		c.VideoFormat = defaults.VideoFormat
+	}
+	if c.Concurrency == 0 {
+		c.Concurrency = defaults.Concurrency
+	}
+
+	if info, err := os.Stat(c.OutputDir); err == nil && !info.IsDir() {
+		return fmt.Errorf("output_dir %s is a file, not a folder", c.OutputDir)
+	}
+	if c.PageSize < 0 {
+		return fmt.Errorf("page_size must be 0 (fit the window) or more, not %d", c.PageSize)
+	}
+	if c.Concurrency < 1 || c.Concurrency > MaxConcurrency {
+		return fmt.Errorf("concurrency must be between 1 and %d, not %d", MaxConcurrency, c.Concurrency)
+	}
+	if strings.ContainsAny(c.AudioFormat, " \t") || strings.ContainsAny(c.VideoFormat, " \t") {
+		return fmt.Errorf("formats can't contain spaces")
+	}
+	for name, site := range c.Sites {
+		if strings.ContainsAny(site.AudioFormat, " \t") || strings.ContainsAny(site.VideoFormat, " \t") {
+			return fmt.Errorf("the formats of sites.%s can't contain spaces", name)
+		}
+	}
+	switch c.DiskCheck {
+	case "", "block", "warn", "off":
+	default:
+		return fmt.Errorf("disk_check must be block, warn or off, not %q", c.DiskCheck)
+	}
+	switch c.SponsorBlock.Mode {
+	case "", "remove", "mark":
+	default:
+		return fmt.Errorf("sponsorblock.mode must be remove, mark or empty, not %q", c.SponsorBlock.Mode)
+	}
+	if api := c.SponsorBlock.API; api != "" {
+		if u, err := url.Parse(api); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("sponsorblock.api must be an http or https URL, not %q", api)
+		}
+	}
+	for _, category := range c.SponsorBlock.Categories {
+		if !slices.Contains(SponsorBlockCategories, category) {
+			return fmt.Errorf("unknown sponsorblock category %q, available: %s", category, strings.Join(SponsorBlockCategories, ", "))
+		}
+	}
+	if c.Hooks.Timeout < 0 {
+		return fmt.Errorf("hooks.timeout must be 0 (%d seconds) or more, not %d", DefaultHookTimeout, c.Hooks.Timeout)
+	}
+	if _, err := ParseRate(c.RateLimit); err != nil {
+		return fmt.Errorf("rate_limit: %v", err)
+	}
+	for _, window := range c.Schedule {
+		if _, err := ParseWindow(window); err != nil {
+			return fmt.Errorf("schedule: %v", err)
+		}
+	}
+	switch c.Notifications.Terminal {
+	case "", "9", "777":
+	default:
+		return fmt.Errorf("notifications.terminal must be 9, 777 or empty, not %q", c.Notifications.Terminal)
+	}
+	switch c.Background {
+	case "", "auto", "light", "dark":
+	default:
+		return fmt.Errorf("background must be light, dark or auto, not %q", c.Background)
+	}
+	return nil
+}
+
+func Current() *Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Save validates cfg and writes it atomically, Current becomes cfg with the overrides applied again
+func Save(cfg *Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(Path(), append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	saved := *cfg
+	applyOverrides(&saved)
+	current = &saved
+	return nil
+}
+
+// AudioFormatFor is the audio format of a site, AudioFormat when the site has none
+func (c *Config) AudioFormatFor(site string) string {
+	if format := c.Sites[strings.ToLower(site)].AudioFormat; format != "" {
+		return format
+	}
+	return c.AudioFormat
+}
+
+// VideoFormatFor is the video format of a site, VideoFormat when the site has none
+func (c *Config) VideoFormatFor(site string) string {
+	if format := c.Sites[strings.ToLower(site)].VideoFormat; format != "" {
+		return format
+	}
+	return c.VideoFormat
+}
+
+func (c *Config) ActiveProfile() Profile {
+	return c.Profiles[c.Profile]
+}
+
+func (c *Config) ProfileNames() []string {
+	var names []string
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ExtractProfileFlag pulls --profile NAME or --profile=NAME out of args, so it works before any subcommand
+func ExtractProfileFlag(args []string) (string, []string) {
+	var profile string
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--profile" || arg == "-profile":
+			if i+1 < len(args) {
+				profile = args[i+1]
+				i++
+			}
+		case strings.HasPrefix(arg, "--profile="):
+			profile = strings.TrimPrefix(arg, "--profile=")
+		case strings.HasPrefix(arg, "-profile="):
+			profile = strings.TrimPrefix(arg, "-profile=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return profile, rest
+}
@@ -0,0 +1,97 @@
+package config
+
+import "testing"
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int64
+		err   bool
+	}{
+		{input: "", want: 0},
+		{input: "  ", want: 0},
+		{input: "1000", want: 1000},
+		{input: "500K", want: 500 << 10},
+		{input: "500k", want: 500 << 10},
+		{input: "2M", want: 2 << 20},
+		{input: "2m", want: 2 << 20},
+		{input: "1.5M", want: 3 << 19},
+		{input: "1g", want: 1 << 30},
+		{input: "2MiB", want: 2 << 20},
+		{input: "2M/s", want: 2 << 20},
+		{input: "2MB", want: 2e6},
+		{input: "0", err: true},
+		{input: "0K", err: true},
+		{input: "fast", err: true},
+		{input: "2X", err: true},
+		{input: "-1M", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.input)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseRate(%q) = %d, want an error", tt.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRate(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRate(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Window
+		err   bool
+	}{
+		{input: "22:00-06:00", want: Window{Start: 22 * 60, End: 6 * 60}},
+		{input: "01:30-05:45", want: Window{Start: 90, End: 345}},
+		{input: " 9:00 - 17:00 ", want: Window{Start: 9 * 60, End: 17 * 60}},
+		{input: "22:00", err: true},
+		{input: "22:00-22:00", err: true},
+		{input: "25:00-06:00", err: true},
+		{input: "22:00-6pm", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseWindow(tt.input)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseWindow(%q) = %+v, want an error", tt.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseWindow(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseWindow(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	tests := []struct {
+		window Window
+		minute int
+		want   bool
+	}{
+		{window: Window{Start: 60, End: 120}, minute: 60, want: true},
+		{window: Window{Start: 60, End: 120}, minute: 119, want: true},
+		{window: Window{Start: 60, End: 120}, minute: 120, want: false},
+		{window: Window{Start: 22 * 60, End: 6 * 60}, minute: 23 * 60, want: true},
+		{window: Window{Start: 22 * 60, End: 6 * 60}, minute: 5 * 60, want: true},
+		{window: Window{Start: 22 * 60, End: 6 * 60}, minute: 12 * 60, want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.window.Contains(tt.minute); got != tt.want {
+			t.Errorf("%+v.Contains(%d) = %v, want %v", tt.window, tt.minute, got, tt.want)
+		}
+	}
+}
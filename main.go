@@ -2,51 +2,75 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/AbdelilahOu/Bubly-cli-app/app"
+	"github.com/AbdelilahOu/Bubly-cli-app/config"
 	"github.com/AbdelilahOu/Bubly-cli-app/utils"
 
-	"github.com/charmbracelet/bubbles/textarea"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
 )
 
 func main() {
-	utils.ClearTerminal()
-
-	ta := textarea.New()
-	ta.Placeholder = "Pass in a url..."
-	ta.Focus()
-
-	ta.Prompt = "┃ "
-	ta.CharLimit = 280
+	demo, args := app.ExtractDemoFlag(os.Args[1:])
+	profile, args := config.ExtractProfileFlag(args)
+	if err := config.Load(profile); err != nil {
+		fmt.Println("could not load config:", err)
+		os.Exit(1)
+	}
 
-	ta.SetWidth(50)
-	ta.SetHeight(2)
+	cfg := config.Current()
+	if err := app.UseTheme(cfg.Theme, cfg.Background); err != nil {
+		fmt.Println("could not load theme:", err)
+		os.Exit(1)
+	}
+	if err := app.UseKeyMap(cfg.Keymap, cfg.Keys); err != nil {
+		fmt.Println("could not load keys:", err)
+		os.Exit(1)
+	}
 
-	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	warning := app.AuthWarning()
+	if demo {
+		app.UseBackend(app.NewDemoBackend())
+		warning = ""
+	}
 
-	ta.ShowLineNumbers = false
+	if len(args) > 0 && args[0] == "batch" {
+		os.Exit(app.RunBatchCommand(args[1:]))
+	}
+	if len(args) > 0 && args[0] == "sync" {
+		os.Exit(app.RunSyncCommand(args[1:]))
+	}
+	if len(args) > 0 && args[0] == "serve" {
+		os.Exit(app.RunServeCommand(args[1:]))
+	}
+	if len(args) == 0 && utils.StdinIsPipe() {
+		os.Exit(app.RunBatchCommand(nil))
+	}
 
-	ta.KeyMap.InsertNewline.SetEnabled(false)
+	utils.ClearTerminal()
 
+	layout := &app.Layout{ItemsPerPage: 5}
+	layout.Configure(cfg.PageSize, cfg.Compact)
 	initialModel := app.AppModel{
-		Choice:           0,
-		Quitting:         false,
-		History:          []string{},
-		Textarea:         ta,
-		Text:             "",
-		IsTextAreaActive: false,
-		IsUrlWritten:     false,
-		PrintingIsDone:   false,
-		PrintingError:    false,
-		CheckingYtdlp:    true,
-		Page:             0,
-		ItemsPerPage:     5,
+		Choice:        0,
+		Quitting:      false,
+		CheckingYtdlp: true,
+		Layout:        layout,
+		Router:        app.NewRouter(app.NewMenuScreen(layout)),
+		Warning:       warning,
 	}
 
 	p := tea.NewProgram(initialModel)
+	stopNotifications := app.WatchProgramQueue(app.SharedQueue(), p, os.Stdout)
 	if _, err := p.Run(); err != nil {
 		fmt.Println("could not start program:", err)
 	}
+	stopNotifications()
+
+	if path, err := app.SaveReport(config.Current().Report, app.NewReport(app.SharedQueue().Jobs())); err != nil {
+		fmt.Println("could not save the report:", err)
+	} else if path != "" {
+		fmt.Println("Report saved to", path)
+	}
 }
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package utils
+
+import "errors"
+
+// FreeSpace is not known on this system, callers skip their checks
+func FreeSpace(dir string) (int64, error) {
+	return 0, errors.New("free space is not known on this system")
+}
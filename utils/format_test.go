@@ -0,0 +1,29 @@
+package utils
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int64
+		ok    bool
+	}{
+		{input: "512B", want: 512, ok: true},
+		{input: "68.71KiB", want: 70359, ok: true},
+		{input: "1.20GiB", want: 1288490188, ok: true},
+		{input: "≈1.20GiB", want: 1288490188, ok: true},
+		{input: "~ 5MiB", want: 5 << 20, ok: true},
+		{input: "3MB", want: 3e6, ok: true},
+		{input: "2 TiB", want: 2 << 40, ok: true},
+		{input: "", ok: false},
+		{input: "5", ok: false},
+		{input: "5 parsecs", ok: false},
+		{input: "N/A", ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseSize(tt.input)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d, %v", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+}
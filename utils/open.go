@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"github.com/muesli/termenv"
+)
+
+// OpenFile opens path with its default app, like a double click would
+func OpenFile(path string) error {
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("cmd", "/c", "start", "", path)
+	case "darwin":
+		cmd = exec.Command("open", path)
+	default:
+		cmd = exec.Command("xdg-open", path)
+	}
+
+	return startDetached(cmd)
+}
+
+// RevealFile shows path selected in the file manager. On Linux that's asked for over D-Bus,
+// file managers without it get the folder opened instead.
+func RevealFile(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		return startDetached(exec.Command("explorer", "/select,"+abs))
+	case "darwin":
+		return startDetached(exec.Command("open", "-R", abs))
+	}
+
+	// a quote would end the GVariant string gdbus parses, it's fine percent-encoded in a URI
+	uri := strings.ReplaceAll((&url.URL{Scheme: "file", Path: abs}).String(), "'", "%27")
+	err = exec.Command("gdbus", "call", "--session",
+		"--dest", "org.freedesktop.FileManager1",
+		"--object-path", "/org/freedesktop/FileManager1",
+		"--method", "org.freedesktop.FileManager1.ShowItems",
+		"['"+uri+"']", "''").Run()
+	if err != nil {
+		return startDetached(exec.Command("xdg-open", filepath.Dir(abs)))
+	}
+	return nil
+}
+
+// CopyToClipboard copies text with the system clipboard, or through the terminal with OSC 52
+// when there's none, like over SSH. It reports whether the system clipboard was used.
+func CopyToClipboard(text string) bool {
+	if err := clipboard.WriteAll(text); err == nil {
+		return true
+	}
+	termenv.Copy(text)
+	return false
+}
+
+// startDetached starts cmd without waiting for it, openers like xdg-open can take a while
+func startDetached(cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
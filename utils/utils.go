@@ -6,9 +6,6 @@ import (
 	"net/http"
 	"os"
 	"runtime"
-
-	"github.com/AbdelilahOu/Bubly-cli-app/types"
-	tea "github.com/charmbracelet/bubbletea"
 )
 
 func CheckYtdlp() bool {
@@ -33,14 +30,7 @@ func CheckFfmpeg() bool {
 	return err == nil
 }
 
-func InstallYtdlp() tea.Cmd {
-	return func() tea.Msg {
-		err := doInstallYtdlp()
-		return types.YtdlpInstalledMsg{Err: err}
-	}
-}
-
-func doInstallYtdlp() error {
+func DownloadYtdlp() error {
 	err := os.MkdirAll("bin", 0755)
 	if err != nil {
 		return err